@@ -0,0 +1,563 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/roboogg133/goon/goon"
+)
+
+// A generator accumulates the source of a generated file.
+type generator struct {
+	pkg *pkgInfo
+	buf bytes.Buffer
+	// helpers prefixes the names of the functions of the generated test, so
+	// that the tests of several goon-gen runs can share a package.
+	helpers string
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// generate returns the source of the methods of the types names of the
+// package in dir, and the source of their test.
+func generate(dir string, names []string) (src, test []byte, err error) {
+	pkg, err := loadPackage(dir, names)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	g := &generator{pkg: pkg}
+	g.header(names, "strconv")
+	for _, name := range names {
+		g.marshal(pkg.structs[name])
+		g.unmarshal(pkg.structs[name])
+	}
+	if src, err = g.format(); err != nil {
+		return nil, nil, err
+	}
+
+	g = &generator{pkg: pkg, helpers: lowerFirst(names[0]) + "TOON"}
+	g.header(names, "reflect", "slices", "strings", "testing")
+	for _, name := range names {
+		g.test(pkg.structs[name])
+	}
+	g.testHelpers(names)
+	if test, err = g.format(); err != nil {
+		return nil, nil, err
+	}
+	return src, test, nil
+}
+
+func (g *generator) header(names []string, imports ...string) {
+	g.printf("// Code generated by \"goon-gen -type=%s\"; DO NOT EDIT.\n\n", strings.Join(names, ","))
+	g.printf("package %s\n\nimport (\n", g.pkg.name)
+	for _, path := range imports {
+		g.printf("%q\n", path)
+	}
+	g.printf("\n\"github.com/roboogg133/goon/goon\"\n)\n")
+}
+
+// format gofmts the generated source, removing the imports it doesn't use.
+func (g *generator) format() ([]byte, error) {
+	src := g.buf.Bytes()
+	for _, path := range []string{"strconv", "reflect"} {
+		if !bytes.Contains(src, []byte(path+".")) {
+			src = bytes.Replace(src, []byte(strconv.Quote(path)+"\n"), nil, 1)
+		}
+	}
+	out, err := format.Source(src)
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return out, nil
+}
+
+// key returns the literal of the key name, formatted as a TOON key and
+// followed by suffix.
+func key(name, suffix string) string {
+	return strconv.Quote(string(goon.AppendKey(nil, name)) + suffix)
+}
+
+// convert returns the conversion of the expression x of type from to the
+// type to, or x itself when they are the same.
+func convert(to, from, x string) string {
+	if to == from {
+		return x
+	}
+	return to + "(" + x + ")"
+}
+
+// appendBasic returns the statement appending x, a value of the basic type
+// b, to dst. Floats may fail, being NaN or infinite.
+func appendBasic(b basicInfo, x string) string {
+	switch b.kind {
+	case "string":
+		return "dst = goon.AppendString(dst, " + convert("string", b.name, x) + ")"
+	case "bool":
+		return "dst = strconv.AppendBool(dst, " + convert("bool", b.name, x) + ")"
+	case "int":
+		return "dst = strconv.AppendInt(dst, " + convert("int64", b.name, x) + ", 10)"
+	case "uint":
+		return "dst = strconv.AppendUint(dst, " + convert("uint64", b.name, x) + ", 10)"
+	}
+	return fmt.Sprintf("if dst, err = goon.AppendFloat(dst, %s, %d); err != nil {\nreturn nil, err\n}", convert("float64", b.name, x), max(b.bits, 32))
+}
+
+// fails reports whether encoding the field f may fail, for its
+// AppendTOONObject method to declare err.
+func (g *generator) fails(f fieldInfo) bool {
+	switch f.typ.class {
+	case prim, ptrPrim:
+		return f.typ.basic.kind == "float"
+	}
+	return true
+}
+
+// marshal writes the MarshalTOON and AppendTOONObject methods of s.
+func (g *generator) marshal(s *structInfo) {
+	g.printf("// MarshalTOON encodes v as a TOON document, like goon.Marshal.\n")
+	g.printf("func (v %s) MarshalTOON() ([]byte, error) {\nreturn goon.Marshal(v)\n}\n\n", s.name)
+
+	g.printf("// AppendTOONObject appends the keys of v to dst, as written by w.\n")
+	g.printf("func (v %s) AppendTOONObject(w *goon.ObjectWriter, dst []byte) ([]byte, error) {\n", s.name)
+	if slices.ContainsFunc(s.fields, g.fails) {
+		g.printf("var err error\n")
+	}
+	for _, f := range s.fields {
+		g.marshalField(f)
+	}
+	g.printf("return dst, nil\n}\n\n")
+}
+
+func (g *generator) marshalField(f fieldInfo) {
+	x := "v." + f.name
+	g.printf("\n")
+	switch f.typ.class {
+	case prim:
+		g.printf("dst = w.Indent(dst, 0)\ndst = append(dst, %s...)\n", key(f.key, " : "))
+		g.printf("%s\ndst = append(dst, '\\n')\n", appendBasic(f.typ.basic, x))
+
+	case ptrPrim:
+		g.nullable(f, func() {
+			g.printf("dst = append(dst, %s...)\n", key(f.key, " : "))
+			g.printf("%s\ndst = append(dst, '\\n')\n", appendBasic(f.typ.basic, "*"+x))
+		})
+
+	case object:
+		g.printf("dst = w.Indent(dst, 0)\ndst = append(dst, %s...)\n", key(f.key, " :\n"))
+		g.printf("if dst, err = w.AppendObject(dst, %s); err != nil {\nreturn nil, err\n}\n", x)
+
+	case ptrObject:
+		g.nullable(f, func() {
+			g.printf("dst = append(dst, %s...)\n", key(f.key, " :\n"))
+			g.printf("if dst, err = w.AppendObject(dst, %s); err != nil {\nreturn nil, err\n}\n", x)
+		})
+
+	case slicePrim, table:
+		if f.omitEmpty {
+			g.printf("if %s != nil {\n", x)
+		} else {
+			g.printf("if %s == nil && w.NilSliceAsNull() {\n", x)
+			g.printf("dst = w.Indent(dst, 0)\ndst = append(dst, %s...)\n} else {\n", key(f.key, " : null\n"))
+		}
+		g.printf("if err = w.CheckArray(%s); err != nil {\nreturn nil, err\n}\n", x)
+		g.printf("dst = w.Indent(dst, 0)\ndst = append(dst, %s...)\n", key(f.key, "["))
+		g.printf("dst = strconv.AppendInt(dst, int64(len(%s)), 10)\n", x)
+		g.printf("if len(%s) == 0 {\ndst = append(dst, \"]:\\n\"...)\n} else {\n", x)
+		if f.typ.class == slicePrim {
+			g.printf("dst = append(dst, \"]: \"...)\n")
+			g.printf("for i, x := range %s {\nif i > 0 {\ndst = append(dst, ',')\n}\n", x)
+			g.printf("%s\n}\ndst = append(dst, '\\n')\n", appendBasic(f.typ.basic, "x"))
+		} else {
+			fields := g.pkg.structs[f.typ.object].fields
+			keys := make([]string, len(fields))
+			for i, rf := range fields {
+				keys[i] = string(goon.AppendKey(nil, rf.key))
+			}
+			g.printf("dst = append(dst, %q...)\n", "]{"+strings.Join(keys, ",")+"}:\n")
+			g.printf("for _, x := range %s {\ndst = w.Indent(dst, 1)\n", x)
+			for i, rf := range fields {
+				if i > 0 {
+					g.printf("dst = append(dst, ',')\n")
+				}
+				g.printf("%s\n", appendBasic(rf.typ.basic, "x."+rf.name))
+			}
+			g.printf("dst = append(dst, '\\n')\n}\n")
+		}
+		g.printf("}\n}\n")
+
+	default:
+		g.printf("if dst, err = w.AppendField(dst, %q, %s, %s); err != nil {\nreturn nil, err\n}\n", f.key, x, f.tag)
+	}
+}
+
+// nullable writes the encoding of the pointer field f, which writes null or
+// nothing when it is nil, depending on `omitempty`, and calls value
+// otherwise.
+func (g *generator) nullable(f fieldInfo, value func()) {
+	x := "v." + f.name
+	if f.omitEmpty {
+		g.printf("if %s != nil {\ndst = w.Indent(dst, 0)\n", x)
+		value()
+		g.printf("}\n")
+		return
+	}
+	g.printf("dst = w.Indent(dst, 0)\n")
+	g.printf("if %s == nil {\ndst = append(dst, %s...)\n} else {\n", x, key(f.key, " : null\n"))
+	value()
+	g.printf("}\n")
+}
+
+// checked reports whether the fields of s must be tracked to report missing
+// required fields or apply defaults.
+func checked(s *structInfo) bool {
+	return slices.ContainsFunc(s.fields, func(f fieldInfo) bool { return f.required || f.hasDef })
+}
+
+// lowerFirst returns name with its first letter in lower case.
+func lowerFirst(name string) string {
+	first, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(first)) + name[size:]
+}
+
+// fieldsVar returns the name of the variable listing the keys of the fields
+// of the generated type name.
+func fieldsVar(name string) string {
+	return lowerFirst(name) + "TOONFields"
+}
+
+// unmarshal writes the UnmarshalTOON and UnmarshalTOONObject methods of s,
+// along with readTOONRow when s is the element of a tabular array.
+func (g *generator) unmarshal(s *structInfo) {
+	g.printf("// %s lists the key of each field of %s followed by its aliases.\n", fieldsVar(s.name), s.name)
+	g.printf("var %s = [][]string{\n", fieldsVar(s.name))
+	for _, f := range s.fields {
+		keys := []string{strconv.Quote(f.key)}
+		for _, alias := range f.aliases {
+			keys = append(keys, strconv.Quote(alias))
+		}
+		g.printf("{%s},\n", strings.Join(keys, ", "))
+	}
+	g.printf("}\n\n")
+
+	g.printf("// UnmarshalTOON decodes the TOON document data into v, like goon.Unmarshal.\n")
+	g.printf("func (v *%s) UnmarshalTOON(data []byte) error {\n", s.name)
+	g.printf("r := goon.NewObjectReader(data)\nif err := v.UnmarshalTOONObject(r); err != nil {\nreturn err\n}\nreturn r.Close()\n}\n\n")
+
+	g.printf("// UnmarshalTOONObject decodes the keys read by r into v.\n")
+	g.printf("func (v *%s) UnmarshalTOONObject(r *goon.ObjectReader) error {\n", s.name)
+	if checked(s) {
+		g.printf("var seen [%d]bool\n", len(s.fields))
+	}
+	g.printf("for r.Next() {\n")
+	g.printf("switch r.Field(%s) {\n", fieldsVar(s.name))
+	for i, f := range s.fields {
+		g.printf("case %d: // %s\n", i, f.key)
+		if checked(s) {
+			g.printf("seen[%d] = true\n", i)
+		}
+		g.unmarshalField(f)
+	}
+	g.printf("default:\nr.Unknown()\n}\n")
+	g.printf("}\nif err := r.Err(); err != nil {\nreturn err\n}\n")
+	g.checkSeen(s, "r")
+	g.printf("return nil\n}\n\n")
+
+	if g.pkg.isTabular(s.name) && g.pkg.used(s.name) {
+		g.printf("// readTOONRow decodes the row of the tabular array t into v.\n")
+		g.printf("func (v *%s) readTOONRow(t *goon.Table, row []goon.Value) error {\n", s.name)
+		if checked(s) {
+			g.printf("var seen [%d]bool\n", len(s.fields))
+		}
+		g.printf("for i := range t.Header {\nswitch t.Field(%s, i) {\n", fieldsVar(s.name))
+		for i, f := range s.fields {
+			g.printf("case %d: // %s\n", i, f.key)
+			if checked(s) {
+				g.printf("seen[%d] = true\n", i)
+			}
+			g.decodeBasic(f.typ.basic, "row[i]", "v."+f.name, "")
+		}
+		g.printf("default:\nt.Unknown(i)\n}\n}\n")
+		g.checkSeen(s, "t")
+		g.printf("return nil\n}\n\n")
+	}
+}
+
+// used reports whether the generated type name is the element of a tabular
+// array field.
+func (pkg *pkgInfo) used(name string) bool {
+	for _, s := range pkg.structs {
+		for _, f := range s.fields {
+			if f.typ.class == table && f.typ.object == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkSeen writes the checks of the required fields and defaults of s once
+// its keys have been read by r.
+func (g *generator) checkSeen(s *structInfo, r string) {
+	for i, f := range s.fields {
+		if f.required {
+			g.printf("if !seen[%d] {\n%s.Missing(%q)\n}\n", i, r, f.key)
+		}
+	}
+	for i, f := range s.fields {
+		if f.hasDef {
+			g.printf("if !seen[%d] {\nif err := goon.DecodeDefault(&v.%s, %q, %q, %q); err != nil {\nreturn err\n}\n}\n", i, f.name, f.key, f.def, f.format)
+		}
+	}
+}
+
+// decodeBasic writes the decoding of the value src, a goon.Value or an
+// ObjectReader, into dst, a value of the basic type b. With ptr, dst is a
+// pointer receiving a new variable.
+func (g *generator) decodeBasic(b basicInfo, src, dst, ptr string) {
+	var call, typ string
+	switch b.kind {
+	case "string":
+		call, typ = src+".Text()", "string"
+	case "bool":
+		call, typ = src+".Bool()", "bool"
+	case "int":
+		call, typ = fmt.Sprintf("%s.Int(%d)", src, b.bits), "int64"
+	case "uint":
+		call, typ = fmt.Sprintf("%s.Uint(%d)", src, b.bits), "uint64"
+	default:
+		call, typ = fmt.Sprintf("%s.Float(%d)", src, max(b.bits, 32)), "float64"
+	}
+	g.printf("x, err := %s\nif err != nil {\nreturn err\n}\n", call)
+	if ptr == "" {
+		g.printf("%s = %s\n", dst, convert(b.name, typ, "x"))
+	} else {
+		g.printf("%s := %s\n%s = &%s\n", ptr, convert(b.name, typ, "x"), dst, ptr)
+	}
+}
+
+func (g *generator) unmarshalField(f fieldInfo) {
+	x := "v." + f.name
+	switch f.typ.class {
+	case prim:
+		g.decodeBasic(f.typ.basic, "r", x, "")
+
+	case ptrPrim:
+		g.printf("if r.IsNull() {\n%s = nil\nbreak\n}\n", x)
+		g.decodeBasic(f.typ.basic, "r", x, "p")
+
+	case object, ptrObject:
+		if f.typ.class == object {
+			g.printf("if r.IsNull() {\n%s = %s{}\nbreak\n}\n", x, f.typ.object)
+		} else {
+			g.printf("if r.IsNull() {\n%s = nil\nbreak\n}\n", x)
+		}
+		g.printf("obj, err := r.Object()\nif err != nil {\nreturn err\n}\n")
+		if f.typ.class == ptrObject {
+			g.printf("if %s == nil {\n%s = new(%s)\n}\n", x, x, f.typ.object)
+		}
+		g.printf("if err := %s.UnmarshalTOONObject(obj); err != nil {\nreturn err\n}\n", x)
+
+	case slicePrim:
+		g.printf("list, err := r.List()\nif err != nil {\nreturn err\n}\n")
+		g.printf("if list == nil {\n%s = nil\nbreak\n}\n", x)
+		g.printf("s := make(%s, len(list))\nfor i := range list {\n", f.typ.expr)
+		g.decodeBasic(f.typ.basic, "list[i]", "s[i]", "")
+		g.printf("}\n%s = s\n", x)
+
+	case table:
+		g.printf("t, err := r.Table()\nif err != nil {\nreturn err\n}\n")
+		g.printf("if t == nil {\n%s = nil\nbreak\n}\n", x)
+		g.printf("s := make(%s, len(t.Rows))\nfor i, row := range t.Rows {\n", f.typ.expr)
+		g.printf("if err := s[i].readTOONRow(t, row); err != nil {\nreturn err\n}\n}\n%s = s\n", x)
+
+	default:
+		g.printf("if err := r.Decode(&%s, %q); err != nil {\nreturn err\n}\n", x, f.format)
+	}
+}
+
+// test writes the test comparing the methods of s with goon.Marshal and
+// goon.Unmarshal, on a value where every field it encodes itself is set, and
+// on the zero value when encoding, under several Encoder options. The value
+// is compared with a copy where the generated types are replaced by
+// struct types without methods, so that nested values are encoded and
+// decoded with reflection as well.
+func (g *generator) test(s *structInfo) {
+	h := g.helpers
+	g.printf("func Test%sTOON(t *testing.T) {\n", s.name)
+	g.printf("v := %s\n\n", g.sample(s.name, 0))
+	g.printf("for _, value := range []%s{v, {}} {\nfor i, configure := range %sOptions {\n", s.name, h)
+	g.printf("got, err := %sEncode(value, configure)\nwant, wantErr := %sEncode(%sPlain(value), configure)\n", h, h, h)
+	g.printf("if got != want || (err == nil) != (wantErr == nil) {\n")
+	g.printf("t.Errorf(\"MarshalTOON with options %%d:\\n%%s%%v\\nwant:\\n%%s%%v\", i, got, err, want, wantErr)\n}\n}\n}\n\n")
+	g.printf("data, err := goon.Marshal(%sPlain(v))\nif err != nil {\nt.Fatal(err)\n}\n", h)
+	g.printf("var decoded %s\nif err := decoded.UnmarshalTOON(data); err != nil {\nt.Fatal(err)\n}\n", s.name)
+	g.printf("reflected := reflect.New(%sPlainType(reflect.TypeFor[%s](), nil))\n", h, s.name)
+	g.printf("if err := goon.Unmarshal(data, reflected.Interface()); err != nil {\nt.Fatal(err)\n}\n")
+	g.printf("if got, want := %sPlain(decoded), reflected.Elem().Interface(); !reflect.DeepEqual(got, want) {\n", h)
+	g.printf("t.Errorf(\"UnmarshalTOON: %%+v\\nwant: %%+v\", got, want)\n}\n}\n\n")
+}
+
+// testHelpers writes the functions used by the tests of the generated types
+// names.
+func (g *generator) testHelpers(names []string) {
+	h := g.helpers
+	g.printf("// %sGenerated is the set of the types goon-gen generated methods for.\n", h)
+	g.printf("var %sGenerated = []reflect.Type{\n", h)
+	for _, name := range names {
+		g.printf("reflect.TypeFor[%s](),\n", name)
+	}
+	g.printf("}\n\n")
+
+	g.printf(`// %[1]sOptions are the configurations of the Encoder the generated methods
+// are compared with reflection under.
+var %[1]sOptions = []func(enc *goon.Encoder){
+	func(enc *goon.Encoder) {},
+	func(enc *goon.Encoder) {
+		enc.SetIndent(4)
+		enc.SetTimeFormat(goon.TimeFormatUnixMilli)
+		enc.SetBinaryEncoding(goon.BinaryHex)
+		enc.SetNilSliceAsNull(true)
+		enc.SetNilMapAsNull(true)
+	},
+	func(enc *goon.Encoder) { enc.SetMaxDepth(2) },
+}
+
+// %[1]sEncode returns the encoding of v by an Encoder set up by configure.
+func %[1]sEncode(v any, configure func(enc *goon.Encoder)) (string, error) {
+	var b strings.Builder
+	enc := goon.NewEncoder(&b)
+	configure(enc)
+	err := enc.Encode(v)
+	return b.String(), err
+}
+
+// %[1]sPlain returns a copy of v where the values of the generated types are
+// replaced by values of struct types with the same fields but none of their
+// methods, which goon encodes and decodes with reflection.
+func %[1]sPlain(v any) any {
+	rv := reflect.ValueOf(v)
+	return %[1]sPlainValue(rv, %[1]sPlainType(rv.Type(), nil)).Interface()
+}
+
+// %[1]sPlainValue returns a copy of v of the type t, returned for the type of
+// v by %[1]sPlainType.
+func %[1]sPlainValue(v reflect.Value, t reflect.Type) reflect.Value {
+	if t == v.Type() {
+		return v
+	}
+	p := reflect.New(t).Elem()
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			p.Set(reflect.New(t.Elem()))
+			p.Elem().Set(%[1]sPlainValue(v.Elem(), t.Elem()))
+		}
+	case reflect.Slice:
+		if !v.IsNil() {
+			p.Set(reflect.MakeSlice(t, v.Len(), v.Len()))
+			for i := range v.Len() {
+				p.Index(i).Set(%[1]sPlainValue(v.Index(i), t.Elem()))
+			}
+		}
+	case reflect.Struct:
+		for i := range t.NumField() {
+			f := t.Field(i)
+			p.Field(i).Set(%[1]sPlainValue(v.FieldByName(f.Name), f.Type))
+		}
+	}
+	return p
+}
+
+// %[1]sPlainType returns the type of the copies of the values of type t made
+// by %[1]sPlain. The generated types in open, being replaced, are kept as
+// they are.
+func %[1]sPlainType(t reflect.Type, open []reflect.Type) reflect.Type {
+	switch t.Kind() {
+	case reflect.Pointer:
+		if elem := %[1]sPlainType(t.Elem(), open); elem != t.Elem() {
+			return reflect.PointerTo(elem)
+		}
+	case reflect.Slice:
+		if elem := %[1]sPlainType(t.Elem(), open); elem != t.Elem() {
+			return reflect.SliceOf(elem)
+		}
+	case reflect.Struct:
+		if !slices.Contains(%[1]sGenerated, t) || slices.Contains(open, t) {
+			return t
+		}
+		var fields []reflect.StructField
+		for i := range t.NumField() {
+			if f := t.Field(i); f.IsExported() {
+				f.Type = %[1]sPlainType(f.Type, append(open, t))
+				f.Index, f.Offset = nil, 0
+				fields = append(fields, f)
+			}
+		}
+		return reflect.StructOf(fields)
+	}
+	return t
+}
+`, h)
+}
+
+// sample returns a composite literal of the generated type name, nested at
+// depth, with a value for every field the generated code encodes itself.
+func (g *generator) sample(name string, depth int) string {
+	if depth > 1 {
+		return name + "{}"
+	}
+
+	var b strings.Builder
+	b.WriteString(name + "{\n")
+	for i, f := range g.pkg.structs[name].fields {
+		var value string
+		switch f.typ.class {
+		case prim:
+			value = sampleBasic(f.typ.basic, i)
+		case ptrPrim:
+			value = fmt.Sprintf("func() *%s { x := %s; return &x }()", f.typ.basic.name, sampleBasic(f.typ.basic, i))
+		case object:
+			value = g.sample(f.typ.object, depth+1)
+		case ptrObject:
+			if depth > 0 {
+				continue
+			}
+			value = "&" + g.sample(f.typ.object, depth+1)
+		case slicePrim:
+			value = fmt.Sprintf("%s{%s, %s}", f.typ.expr, sampleBasic(f.typ.basic, i), sampleBasic(f.typ.basic, i+1))
+		case table:
+			row := g.sample(f.typ.object, depth+1)
+			value = fmt.Sprintf("%s{%s, %s}", f.typ.expr, row, row)
+		default:
+			continue
+		}
+		b.WriteString(f.name + ": " + value + ",\n")
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// sampleBasic returns a value of the basic type b, varying with i.
+func sampleBasic(b basicInfo, i int) string {
+	var value string
+	switch b.kind {
+	case "string":
+		value = strconv.Quote([]string{"hello, world", "x", "42", "a \"quoted\" value"}[i%4])
+	case "bool":
+		value = strconv.FormatBool(i%2 == 0)
+	case "int", "uint":
+		value = strconv.Itoa(i%100 + 1)
+	default:
+		value = strconv.Itoa(i+1) + ".1"
+	}
+	return b.name + "(" + value + ")"
+}
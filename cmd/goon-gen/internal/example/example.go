@@ -0,0 +1,39 @@
+// Package example holds the types goon-gen is tested with. The generated
+// test, team_toon_test.go, checks the generated methods against the
+// reflective encoder and decoder.
+package example
+
+import "time"
+
+//go:generate go run github.com/roboogg133/goon/cmd/goon-gen -type=Team,Member,Address
+
+// Level is the seniority of a Member.
+type Level int
+
+type Team struct {
+	Name    string            `toon:"name,required"`
+	Tags    []string          `toon:"tags"`
+	Lead    *Member           `toon:"lead" omitempty:"true"`
+	Members []Member          `toon:"members"`
+	Office  Address           `toon:"office"`
+	Budget  *float64          `toon:"budget"`
+	Size    int               `toon:"size" default:"1"`
+	Founded time.Time         `toon:"founded" format:"unix"`
+	Labels  map[string]string `toon:"labels"`
+	Ignored string
+}
+
+type Member struct {
+	ID     uint32  `toon:"id,required"`
+	Name   string  `toon:"name"`
+	Level  Level   `toon:"level,alias=rank"`
+	Active bool    `toon:"active"`
+	Score  float32 `toon:"score" default:"1.5"`
+}
+
+type Address struct {
+	Street string   `toon:"street"`
+	City   string   `toon:"city"`
+	Zip    *string  `toon:"zip" omitempty:"true"`
+	Floors []uint16 `toon:"floors"`
+}
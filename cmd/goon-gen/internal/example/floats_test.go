@@ -0,0 +1,34 @@
+package example_test
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/roboogg133/goon/cmd/goon-gen/internal/example"
+	"github.com/roboogg133/goon/goon"
+)
+
+func TestFloats(t *testing.T) {
+	got, err := example.Member{Score: 0.1}.MarshalTOON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(got), "\nscore : 0.1") {
+		t.Errorf("got:\n%s", got)
+	}
+
+	inf := math.Inf(-1)
+	for _, v := range []goon.Marshaler{
+		example.Member{Score: float32(math.NaN())},
+		example.Team{Budget: &inf},
+		example.Team{Members: []example.Member{{Score: float32(inf)}}},
+	} {
+		_, err := v.MarshalTOON()
+		var valueErr *goon.UnsupportedValueError
+		if !errors.As(err, &valueErr) {
+			t.Errorf("%+v: got %v, want an UnsupportedValueError", v, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package example_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/roboogg133/goon/cmd/goon-gen/internal/example"
+	"github.com/roboogg133/goon/goon"
+)
+
+// TestDecoderOptions checks that the generated methods apply the options and
+// limits of the Decoder, at the root and nested in other values.
+func TestDecoderOptions(t *testing.T) {
+	decode := func(doc string, v any, configure func(*goon.Decoder)) error {
+		dec := goon.NewDecoder(strings.NewReader(doc))
+		configure(dec)
+		return dec.Decode(v)
+	}
+
+	t.Run("unknown keys", func(t *testing.T) {
+		doc := "name : x\nbogus : 1\nlead :\n  id : 1\n  extra : 2\nmembers[1]{id,nope}:\n  1,2"
+		for _, tc := range []struct {
+			name string
+			doc  string
+			v    any
+			want []string
+		}{
+			{"root", doc, new(example.Team), []string{"bogus", "lead.extra", "members[].nope"}},
+			{"nested", "team :\n" + indent(doc), new(struct {
+				Team example.Team `toon:"team"`
+			}), []string{"team.bogus", "team.lead.extra", "team.members[].nope"}},
+		} {
+			err := decode(tc.doc, tc.v, (*goon.Decoder).DisallowUnknownFields)
+			var fieldErr *goon.FieldError
+			if !errors.As(err, &fieldErr) || !reflect.DeepEqual(fieldErr.Unknown, tc.want) {
+				t.Errorf("%s: got %v, want unknown %v", tc.name, err, tc.want)
+			}
+		}
+
+		var team example.Team
+		if err := goon.Unmarshal([]byte(doc), &team); err != nil {
+			t.Errorf("Unmarshal rejected unknown keys: %v", err)
+		}
+	})
+
+	t.Run("aliases and case", func(t *testing.T) {
+		var reported []string
+		var team example.Team
+		err := decode("NAME : x\nlead :\n  id : 1\n  rank : 3\nmembers[1]{ID,Rank}:\n  2,4", &team, func(dec *goon.Decoder) {
+			dec.MatchCaseInsensitive()
+			dec.SetAliasReporter(func(path, key string) {
+				reported = append(reported, path+"="+key)
+			})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if team.Name != "x" || team.Lead.Level != 3 || team.Members[0].ID != 2 || team.Members[0].Level != 4 {
+			t.Errorf("got %+v", team)
+		}
+		want := []string{"name=NAME", "lead.level=rank", "members[].id=ID", "members[].level=Rank"}
+		if !reflect.DeepEqual(reported, want) {
+			t.Errorf("reported %v, want %v", reported, want)
+		}
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		for _, tc := range []struct {
+			doc    string
+			limits goon.Limits
+			limit  string
+		}{
+			{"name : x\nmembers[2]{id}:\n  1\n  2", goon.Limits{MaxArrayLength: 1}, "array length"},
+			{"name : x\ntags[2]: a,b", goon.Limits{MaxArrayLength: 1}, "array length"},
+			{"name : x\nmembers[1]{id,name}:\n  1,a", goon.Limits{MaxKeys: 1}, "keys per object"},
+			{"name : x\nsize : 1", goon.Limits{MaxKeys: 1}, "keys per object"},
+			{"name : x\noffice :\n  city : y", goon.Limits{MaxDepth: 1}, "depth"},
+		} {
+			var team example.Team
+			err := decode(tc.doc, &team, func(dec *goon.Decoder) { dec.SetLimits(tc.limits) })
+			var limitErr *goon.LimitError
+			if !errors.As(err, &limitErr) || limitErr.Limit != tc.limit {
+				t.Errorf("%q: got %v, want a %s LimitError", tc.doc, err, tc.limit)
+			}
+		}
+	})
+
+	t.Run("short arrays with large headers", func(t *testing.T) {
+		for _, doc := range []string{
+			"name : x\nmembers[300000000]{id}:\n  1",
+			"name : x\noffice :\n  floors[300000000]:\n    - 1",
+		} {
+			var team example.Team
+			if err := goon.Unmarshal([]byte(doc), &team); err == nil {
+				t.Errorf("%q: expected an error", doc)
+			}
+		}
+	})
+
+	t.Run("arrays ending early", func(t *testing.T) {
+		for _, doc := range []string{
+			"name : x\ntags[2]:\n  - a\nsize : 3",
+			"name : x\nmembers[2]{id}:\n  1\nsize : 3",
+		} {
+			var team example.Team
+			var syntaxErr *goon.SyntaxError
+			if err := goon.Unmarshal([]byte(doc), &team); !errors.As(err, &syntaxErr) {
+				t.Errorf("%q: got %v, want a SyntaxError", doc, err)
+			}
+		}
+	})
+
+	t.Run("strict indentation", func(t *testing.T) {
+		var team example.Team
+		err := decode("name : x\noffice :\n  city : y\n   street : z", &team, (*goon.Decoder).StrictIndentation)
+		var syntaxErr *goon.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("got %v, want a SyntaxError", err)
+		}
+	})
+}
+
+// indent indents every line of doc by one level.
+func indent(doc string) string {
+	return "  " + strings.ReplaceAll(doc, "\n", "\n  ")
+}
@@ -0,0 +1,558 @@
+// Code generated by "goon-gen -type=Team,Member,Address"; DO NOT EDIT.
+
+package example
+
+import (
+	"strconv"
+
+	"github.com/roboogg133/goon/goon"
+)
+
+// MarshalTOON encodes v as a TOON document, like goon.Marshal.
+func (v Team) MarshalTOON() ([]byte, error) {
+	return goon.Marshal(v)
+}
+
+// AppendTOONObject appends the keys of v to dst, as written by w.
+func (v Team) AppendTOONObject(w *goon.ObjectWriter, dst []byte) ([]byte, error) {
+	var err error
+
+	dst = w.Indent(dst, 0)
+	dst = append(dst, "name : "...)
+	dst = goon.AppendString(dst, v.Name)
+	dst = append(dst, '\n')
+
+	if v.Tags == nil && w.NilSliceAsNull() {
+		dst = w.Indent(dst, 0)
+		dst = append(dst, "tags : null\n"...)
+	} else {
+		if err = w.CheckArray(v.Tags); err != nil {
+			return nil, err
+		}
+		dst = w.Indent(dst, 0)
+		dst = append(dst, "tags["...)
+		dst = strconv.AppendInt(dst, int64(len(v.Tags)), 10)
+		if len(v.Tags) == 0 {
+			dst = append(dst, "]:\n"...)
+		} else {
+			dst = append(dst, "]: "...)
+			for i, x := range v.Tags {
+				if i > 0 {
+					dst = append(dst, ',')
+				}
+				dst = goon.AppendString(dst, x)
+			}
+			dst = append(dst, '\n')
+		}
+	}
+
+	if v.Lead != nil {
+		dst = w.Indent(dst, 0)
+		dst = append(dst, "lead :\n"...)
+		if dst, err = w.AppendObject(dst, v.Lead); err != nil {
+			return nil, err
+		}
+	}
+
+	if v.Members == nil && w.NilSliceAsNull() {
+		dst = w.Indent(dst, 0)
+		dst = append(dst, "members : null\n"...)
+	} else {
+		if err = w.CheckArray(v.Members); err != nil {
+			return nil, err
+		}
+		dst = w.Indent(dst, 0)
+		dst = append(dst, "members["...)
+		dst = strconv.AppendInt(dst, int64(len(v.Members)), 10)
+		if len(v.Members) == 0 {
+			dst = append(dst, "]:\n"...)
+		} else {
+			dst = append(dst, "]{id,name,level,active,score}:\n"...)
+			for _, x := range v.Members {
+				dst = w.Indent(dst, 1)
+				dst = strconv.AppendUint(dst, uint64(x.ID), 10)
+				dst = append(dst, ',')
+				dst = goon.AppendString(dst, x.Name)
+				dst = append(dst, ',')
+				dst = strconv.AppendInt(dst, int64(x.Level), 10)
+				dst = append(dst, ',')
+				dst = strconv.AppendBool(dst, x.Active)
+				dst = append(dst, ',')
+				if dst, err = goon.AppendFloat(dst, float64(x.Score), 32); err != nil {
+					return nil, err
+				}
+				dst = append(dst, '\n')
+			}
+		}
+	}
+
+	dst = w.Indent(dst, 0)
+	dst = append(dst, "office :\n"...)
+	if dst, err = w.AppendObject(dst, v.Office); err != nil {
+		return nil, err
+	}
+
+	dst = w.Indent(dst, 0)
+	if v.Budget == nil {
+		dst = append(dst, "budget : null\n"...)
+	} else {
+		dst = append(dst, "budget : "...)
+		if dst, err = goon.AppendFloat(dst, *v.Budget, 64); err != nil {
+			return nil, err
+		}
+		dst = append(dst, '\n')
+	}
+
+	dst = w.Indent(dst, 0)
+	dst = append(dst, "size : "...)
+	dst = strconv.AppendInt(dst, int64(v.Size), 10)
+	dst = append(dst, '\n')
+
+	if dst, err = w.AppendField(dst, "founded", v.Founded, `toon:"founded" format:"unix"`); err != nil {
+		return nil, err
+	}
+
+	if dst, err = w.AppendField(dst, "labels", v.Labels, `toon:"labels"`); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// teamTOONFields lists the key of each field of Team followed by its aliases.
+var teamTOONFields = [][]string{
+	{"name"},
+	{"tags"},
+	{"lead"},
+	{"members"},
+	{"office"},
+	{"budget"},
+	{"size"},
+	{"founded"},
+	{"labels"},
+}
+
+// UnmarshalTOON decodes the TOON document data into v, like goon.Unmarshal.
+func (v *Team) UnmarshalTOON(data []byte) error {
+	r := goon.NewObjectReader(data)
+	if err := v.UnmarshalTOONObject(r); err != nil {
+		return err
+	}
+	return r.Close()
+}
+
+// UnmarshalTOONObject decodes the keys read by r into v.
+func (v *Team) UnmarshalTOONObject(r *goon.ObjectReader) error {
+	var seen [9]bool
+	for r.Next() {
+		switch r.Field(teamTOONFields) {
+		case 0: // name
+			seen[0] = true
+			x, err := r.Text()
+			if err != nil {
+				return err
+			}
+			v.Name = x
+		case 1: // tags
+			seen[1] = true
+			list, err := r.List()
+			if err != nil {
+				return err
+			}
+			if list == nil {
+				v.Tags = nil
+				break
+			}
+			s := make([]string, len(list))
+			for i := range list {
+				x, err := list[i].Text()
+				if err != nil {
+					return err
+				}
+				s[i] = x
+			}
+			v.Tags = s
+		case 2: // lead
+			seen[2] = true
+			if r.IsNull() {
+				v.Lead = nil
+				break
+			}
+			obj, err := r.Object()
+			if err != nil {
+				return err
+			}
+			if v.Lead == nil {
+				v.Lead = new(Member)
+			}
+			if err := v.Lead.UnmarshalTOONObject(obj); err != nil {
+				return err
+			}
+		case 3: // members
+			seen[3] = true
+			t, err := r.Table()
+			if err != nil {
+				return err
+			}
+			if t == nil {
+				v.Members = nil
+				break
+			}
+			s := make([]Member, len(t.Rows))
+			for i, row := range t.Rows {
+				if err := s[i].readTOONRow(t, row); err != nil {
+					return err
+				}
+			}
+			v.Members = s
+		case 4: // office
+			seen[4] = true
+			if r.IsNull() {
+				v.Office = Address{}
+				break
+			}
+			obj, err := r.Object()
+			if err != nil {
+				return err
+			}
+			if err := v.Office.UnmarshalTOONObject(obj); err != nil {
+				return err
+			}
+		case 5: // budget
+			seen[5] = true
+			if r.IsNull() {
+				v.Budget = nil
+				break
+			}
+			x, err := r.Float(64)
+			if err != nil {
+				return err
+			}
+			p := x
+			v.Budget = &p
+		case 6: // size
+			seen[6] = true
+			x, err := r.Int(0)
+			if err != nil {
+				return err
+			}
+			v.Size = int(x)
+		case 7: // founded
+			seen[7] = true
+			if err := r.Decode(&v.Founded, "unix"); err != nil {
+				return err
+			}
+		case 8: // labels
+			seen[8] = true
+			if err := r.Decode(&v.Labels, ""); err != nil {
+				return err
+			}
+		default:
+			r.Unknown()
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if !seen[0] {
+		r.Missing("name")
+	}
+	if !seen[6] {
+		if err := goon.DecodeDefault(&v.Size, "size", "1", ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalTOON encodes v as a TOON document, like goon.Marshal.
+func (v Member) MarshalTOON() ([]byte, error) {
+	return goon.Marshal(v)
+}
+
+// AppendTOONObject appends the keys of v to dst, as written by w.
+func (v Member) AppendTOONObject(w *goon.ObjectWriter, dst []byte) ([]byte, error) {
+	var err error
+
+	dst = w.Indent(dst, 0)
+	dst = append(dst, "id : "...)
+	dst = strconv.AppendUint(dst, uint64(v.ID), 10)
+	dst = append(dst, '\n')
+
+	dst = w.Indent(dst, 0)
+	dst = append(dst, "name : "...)
+	dst = goon.AppendString(dst, v.Name)
+	dst = append(dst, '\n')
+
+	dst = w.Indent(dst, 0)
+	dst = append(dst, "level : "...)
+	dst = strconv.AppendInt(dst, int64(v.Level), 10)
+	dst = append(dst, '\n')
+
+	dst = w.Indent(dst, 0)
+	dst = append(dst, "active : "...)
+	dst = strconv.AppendBool(dst, v.Active)
+	dst = append(dst, '\n')
+
+	dst = w.Indent(dst, 0)
+	dst = append(dst, "score : "...)
+	if dst, err = goon.AppendFloat(dst, float64(v.Score), 32); err != nil {
+		return nil, err
+	}
+	dst = append(dst, '\n')
+	return dst, nil
+}
+
+// memberTOONFields lists the key of each field of Member followed by its aliases.
+var memberTOONFields = [][]string{
+	{"id"},
+	{"name"},
+	{"level", "rank"},
+	{"active"},
+	{"score"},
+}
+
+// UnmarshalTOON decodes the TOON document data into v, like goon.Unmarshal.
+func (v *Member) UnmarshalTOON(data []byte) error {
+	r := goon.NewObjectReader(data)
+	if err := v.UnmarshalTOONObject(r); err != nil {
+		return err
+	}
+	return r.Close()
+}
+
+// UnmarshalTOONObject decodes the keys read by r into v.
+func (v *Member) UnmarshalTOONObject(r *goon.ObjectReader) error {
+	var seen [5]bool
+	for r.Next() {
+		switch r.Field(memberTOONFields) {
+		case 0: // id
+			seen[0] = true
+			x, err := r.Uint(32)
+			if err != nil {
+				return err
+			}
+			v.ID = uint32(x)
+		case 1: // name
+			seen[1] = true
+			x, err := r.Text()
+			if err != nil {
+				return err
+			}
+			v.Name = x
+		case 2: // level
+			seen[2] = true
+			x, err := r.Int(0)
+			if err != nil {
+				return err
+			}
+			v.Level = Level(x)
+		case 3: // active
+			seen[3] = true
+			x, err := r.Bool()
+			if err != nil {
+				return err
+			}
+			v.Active = x
+		case 4: // score
+			seen[4] = true
+			x, err := r.Float(32)
+			if err != nil {
+				return err
+			}
+			v.Score = float32(x)
+		default:
+			r.Unknown()
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if !seen[0] {
+		r.Missing("id")
+	}
+	if !seen[4] {
+		if err := goon.DecodeDefault(&v.Score, "score", "1.5", ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTOONRow decodes the row of the tabular array t into v.
+func (v *Member) readTOONRow(t *goon.Table, row []goon.Value) error {
+	var seen [5]bool
+	for i := range t.Header {
+		switch t.Field(memberTOONFields, i) {
+		case 0: // id
+			seen[0] = true
+			x, err := row[i].Uint(32)
+			if err != nil {
+				return err
+			}
+			v.ID = uint32(x)
+		case 1: // name
+			seen[1] = true
+			x, err := row[i].Text()
+			if err != nil {
+				return err
+			}
+			v.Name = x
+		case 2: // level
+			seen[2] = true
+			x, err := row[i].Int(0)
+			if err != nil {
+				return err
+			}
+			v.Level = Level(x)
+		case 3: // active
+			seen[3] = true
+			x, err := row[i].Bool()
+			if err != nil {
+				return err
+			}
+			v.Active = x
+		case 4: // score
+			seen[4] = true
+			x, err := row[i].Float(32)
+			if err != nil {
+				return err
+			}
+			v.Score = float32(x)
+		default:
+			t.Unknown(i)
+		}
+	}
+	if !seen[0] {
+		t.Missing("id")
+	}
+	if !seen[4] {
+		if err := goon.DecodeDefault(&v.Score, "score", "1.5", ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalTOON encodes v as a TOON document, like goon.Marshal.
+func (v Address) MarshalTOON() ([]byte, error) {
+	return goon.Marshal(v)
+}
+
+// AppendTOONObject appends the keys of v to dst, as written by w.
+func (v Address) AppendTOONObject(w *goon.ObjectWriter, dst []byte) ([]byte, error) {
+	var err error
+
+	dst = w.Indent(dst, 0)
+	dst = append(dst, "street : "...)
+	dst = goon.AppendString(dst, v.Street)
+	dst = append(dst, '\n')
+
+	dst = w.Indent(dst, 0)
+	dst = append(dst, "city : "...)
+	dst = goon.AppendString(dst, v.City)
+	dst = append(dst, '\n')
+
+	if v.Zip != nil {
+		dst = w.Indent(dst, 0)
+		dst = append(dst, "zip : "...)
+		dst = goon.AppendString(dst, *v.Zip)
+		dst = append(dst, '\n')
+	}
+
+	if v.Floors == nil && w.NilSliceAsNull() {
+		dst = w.Indent(dst, 0)
+		dst = append(dst, "floors : null\n"...)
+	} else {
+		if err = w.CheckArray(v.Floors); err != nil {
+			return nil, err
+		}
+		dst = w.Indent(dst, 0)
+		dst = append(dst, "floors["...)
+		dst = strconv.AppendInt(dst, int64(len(v.Floors)), 10)
+		if len(v.Floors) == 0 {
+			dst = append(dst, "]:\n"...)
+		} else {
+			dst = append(dst, "]: "...)
+			for i, x := range v.Floors {
+				if i > 0 {
+					dst = append(dst, ',')
+				}
+				dst = strconv.AppendUint(dst, uint64(x), 10)
+			}
+			dst = append(dst, '\n')
+		}
+	}
+	return dst, nil
+}
+
+// addressTOONFields lists the key of each field of Address followed by its aliases.
+var addressTOONFields = [][]string{
+	{"street"},
+	{"city"},
+	{"zip"},
+	{"floors"},
+}
+
+// UnmarshalTOON decodes the TOON document data into v, like goon.Unmarshal.
+func (v *Address) UnmarshalTOON(data []byte) error {
+	r := goon.NewObjectReader(data)
+	if err := v.UnmarshalTOONObject(r); err != nil {
+		return err
+	}
+	return r.Close()
+}
+
+// UnmarshalTOONObject decodes the keys read by r into v.
+func (v *Address) UnmarshalTOONObject(r *goon.ObjectReader) error {
+	for r.Next() {
+		switch r.Field(addressTOONFields) {
+		case 0: // street
+			x, err := r.Text()
+			if err != nil {
+				return err
+			}
+			v.Street = x
+		case 1: // city
+			x, err := r.Text()
+			if err != nil {
+				return err
+			}
+			v.City = x
+		case 2: // zip
+			if r.IsNull() {
+				v.Zip = nil
+				break
+			}
+			x, err := r.Text()
+			if err != nil {
+				return err
+			}
+			p := x
+			v.Zip = &p
+		case 3: // floors
+			list, err := r.List()
+			if err != nil {
+				return err
+			}
+			if list == nil {
+				v.Floors = nil
+				break
+			}
+			s := make([]uint16, len(list))
+			for i := range list {
+				x, err := list[i].Uint(16)
+				if err != nil {
+					return err
+				}
+				s[i] = uint16(x)
+			}
+			v.Floors = s
+		default:
+			r.Unknown()
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	return nil
+}
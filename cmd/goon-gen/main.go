@@ -0,0 +1,72 @@
+// Goon-gen generates MarshalTOON and UnmarshalTOON methods for struct types,
+// which encode and decode them without reflection, producing the same
+// documents as goon.Marshal and goon.Unmarshal.
+//
+// Usage:
+//
+//	goon-gen -type=Person,Team [-output=file] [dir]
+//
+// It is meant to be run by go generate, from a line such as
+//
+//	//go:generate go run github.com/roboogg133/goon/cmd/goon-gen -type=Person
+//
+// The methods are written to <type>_toon.go, after the first type, in the
+// package directory dir, which defaults to the current one. A test checking
+// them against the reflective encoder and decoder is written next to it, to
+// <type>_toon_test.go.
+//
+// Fields of strings, booleans and numbers, pointers to them and slices of
+// them, and of the other types listed, and pointers to them, are encoded by
+// the generated code. So are slices of those types whose fields are all
+// strings, booleans or numbers, as tabular arrays. Any other field is handed
+// over to ObjectWriter.AppendField and ObjectReader.Decode, which use
+// reflection.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("goon-gen: ")
+
+	typeNames := flag.String("type", "", "comma-separated list of type names; must be set")
+	output := flag.String("output", "", "output file name; default <dir>/<type>_toon.go")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: goon-gen -type=T[,T...] [-output=file] [dir]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	types := strings.Split(*typeNames, ",")
+
+	src, test, err := generate(dir, types)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	name := *output
+	if name == "" {
+		name = filepath.Join(dir, strings.ToLower(types[0])+"_toon.go")
+	}
+	if err := os.WriteFile(name, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(strings.TrimSuffix(name, ".go")+"_test.go", test, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Run("example is up to date", func(t *testing.T) {
+		src, test, err := generate("internal/example", []string{"Team", "Member", "Address"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, got := range map[string][]byte{"team_toon.go": src, "team_toon_test.go": test} {
+			want, err := os.ReadFile("internal/example/" + name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("%s is out of date, run go generate ./cmd/goon-gen/...", name)
+			}
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, tc := range []struct {
+			types []string
+			want  string
+		}{
+			{[]string{"Missing"}, "type Missing not found"},
+			{[]string{"Level"}, "type Level is not a struct type"},
+		} {
+			_, _, err := generate("internal/example", tc.types)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("generate(%v) = %v, want error containing %q", tc.types, err, tc.want)
+			}
+		}
+	})
+}
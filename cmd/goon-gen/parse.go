@@ -0,0 +1,345 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A class is the way the generated code handles the values of a type.
+type class int
+
+const (
+	// fallback types are encoded and decoded with reflection.
+	fallback class = iota
+	// prim types are strings, booleans and numbers.
+	prim
+	ptrPrim
+	// object types are the types goon-gen generates methods for.
+	object
+	ptrObject
+	slicePrim
+	// table types are slices of objects whose fields are all prim, written
+	// as tabular arrays.
+	table
+)
+
+// A typeInfo describes the type of a field.
+type typeInfo struct {
+	class class
+	// expr is the type as written in the source, such as `[]Tag`.
+	expr string
+	// basic is the type of the primitive values of prim, ptrPrim and
+	// slicePrim types.
+	basic basicInfo
+	// object is the element type of object, ptrObject and table types.
+	object string
+}
+
+// A basicInfo describes a type whose underlying type is a string, boolean
+// or numeric type.
+type basicInfo struct {
+	// name is the name of the type, such as `Level` or `int32`.
+	name string
+	// kind is one of "string", "bool", "int", "uint" and "float".
+	kind string
+	// bits is the bit size of numbers, 0 for int, uint and uintptr.
+	bits int
+}
+
+// A fieldInfo is a struct field with a `toon` tag.
+type fieldInfo struct {
+	// name is the name of the Go field, and key its TOON key.
+	name      string
+	key       string
+	aliases   []string
+	tag       string
+	omitEmpty bool
+	format    string
+	required  bool
+	def       string
+	hasDef    bool
+	typ       typeInfo
+}
+
+// A structInfo is a struct type goon-gen generates methods for.
+type structInfo struct {
+	name   string
+	fields []fieldInfo
+}
+
+// A pkgInfo holds the type declarations of the package being generated.
+type pkgInfo struct {
+	name  string
+	specs map[string]*ast.TypeSpec
+	// generated is the set of types listed on the command line, and custom
+	// the set of types declaring their own MarshalTOON or UnmarshalTOON.
+	generated map[string]bool
+	custom    map[string]bool
+	structs   map[string]*structInfo
+}
+
+var basicKinds = map[string]basicInfo{
+	"string":  {"string", "string", 0},
+	"bool":    {"bool", "bool", 0},
+	"int":     {"int", "int", 0},
+	"int8":    {"int8", "int", 8},
+	"int16":   {"int16", "int", 16},
+	"int32":   {"int32", "int", 32},
+	"rune":    {"rune", "int", 32},
+	"int64":   {"int64", "int", 64},
+	"uint":    {"uint", "uint", 0},
+	"uint8":   {"uint8", "uint", 8},
+	"byte":    {"byte", "uint", 8},
+	"uint16":  {"uint16", "uint", 16},
+	"uint32":  {"uint32", "uint", 32},
+	"uint64":  {"uint64", "uint", 64},
+	"uintptr": {"uintptr", "uint", 0},
+	"float32": {"float32", "float", 32},
+	"float64": {"float64", "float", 64},
+}
+
+// loadPackage parses the Go files of dir, except tests, and collects the
+// struct types names.
+func loadPackage(dir string, names []string) (*pkgInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := &pkgInfo{
+		specs:     make(map[string]*ast.TypeSpec),
+		generated: make(map[string]bool),
+		custom:    make(map[string]bool),
+		structs:   make(map[string]*structInfo),
+	}
+	fset := token.NewFileSet()
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		if pkg.name == "" {
+			pkg.name = file.Name.Name
+		}
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok {
+				if fn.Recv != nil && (fn.Name.Name == "MarshalTOON" || fn.Name.Name == "UnmarshalTOON") {
+					pkg.custom[embeddedName(fn.Recv.List[0].Type)] = true
+				}
+				continue
+			}
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				pkg.specs[ts.Name.Name] = ts
+			}
+		}
+	}
+	if pkg.name == "" {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+
+	for _, name := range names {
+		ts, ok := pkg.specs[name]
+		if !ok {
+			return nil, fmt.Errorf("type %s not found in %s", name, dir)
+		}
+		if _, ok := ts.Type.(*ast.StructType); !ok || ts.TypeParams != nil || ts.Assign.IsValid() {
+			return nil, fmt.Errorf("type %s is not a struct type", name)
+		}
+		pkg.generated[name] = true
+	}
+	for _, name := range names {
+		s, err := pkg.structOf(name)
+		if err != nil {
+			return nil, err
+		}
+		pkg.structs[name] = s
+	}
+	return pkg, nil
+}
+
+// structOf collects the fields of the struct type name.
+func (pkg *pkgInfo) structOf(name string) (*structInfo, error) {
+	s := &structInfo{name: name}
+	for _, f := range pkg.specs[name].Type.(*ast.StructType).Fields.List {
+		var tag reflect.StructTag
+		if f.Tag != nil {
+			unquoted, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid tag %s", name, f.Tag.Value)
+			}
+			tag = reflect.StructTag(unquoted)
+		}
+		toon, ok := tag.Lookup("toon")
+		if !ok || toon == "" {
+			continue
+		}
+
+		names := f.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{{Name: embeddedName(f.Type)}}
+		}
+		key, opts, _ := strings.Cut(toon, ",")
+		typ := pkg.classify(f.Type)
+		for _, n := range names {
+			fi := fieldInfo{
+				name:      n.Name,
+				key:       key,
+				tag:       f.Tag.Value,
+				omitEmpty: tag.Get("omitempty") != "",
+				format:    tag.Get("format"),
+				typ:       typ,
+			}
+			if fi.key == "" {
+				fi.key = n.Name
+			}
+			fi.def, fi.hasDef = tag.Lookup("default")
+			for opt := range strings.SplitSeq(opts, ",") {
+				if alias, ok := strings.CutPrefix(opt, "alias="); ok {
+					fi.aliases = strings.Split(alias, "|")
+				}
+				if opt == "required" {
+					fi.required = true
+				}
+			}
+			s.fields = append(s.fields, fi)
+		}
+	}
+	return s, nil
+}
+
+// embeddedName returns the field name of an embedded type.
+func embeddedName(expr ast.Expr) string {
+	switch x := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(x.X)
+	case *ast.SelectorExpr:
+		return x.Sel.Name
+	case *ast.Ident:
+		return x.Name
+	}
+	return ""
+}
+
+// classify returns how the values of the type expr are handled.
+func (pkg *pkgInfo) classify(expr ast.Expr) typeInfo {
+	typ := typeInfo{expr: exprString(expr)}
+	switch x := expr.(type) {
+	case *ast.Ident:
+		if b, ok := pkg.basicOf(x.Name, 0); ok {
+			typ.class, typ.basic = prim, b
+		} else if pkg.generated[x.Name] {
+			typ.class, typ.object = object, x.Name
+		} else if ts, ok := pkg.specs[x.Name]; ok && ts.TypeParams == nil && !ts.Assign.IsValid() && !pkg.custom[x.Name] {
+			if _, ok := ts.Type.(*ast.ArrayType); ok {
+				elem := pkg.classify(ts.Type)
+				typ.class, typ.basic, typ.object = elem.class, elem.basic, elem.object
+			}
+		}
+
+	case *ast.StarExpr:
+		switch elem := pkg.classify(x.X); elem.class {
+		case prim:
+			typ.class, typ.basic = ptrPrim, elem.basic
+		case object:
+			typ.class, typ.object = ptrObject, elem.object
+		}
+
+	case *ast.ArrayType:
+		if x.Len != nil {
+			break
+		}
+		switch elem := pkg.classify(x.Elt); elem.class {
+		case prim:
+			// Byte slices are encoded as base64 strings.
+			if elem.basic.kind != "uint" || elem.basic.bits != 8 {
+				typ.class, typ.basic = slicePrim, elem.basic
+			}
+		case object:
+			if pkg.isTabular(elem.object) {
+				typ.class, typ.object = table, elem.object
+			}
+		}
+	}
+	return typ
+}
+
+// basicOf returns the basic type of name, a predeclared type or a type
+// declared in the package over one.
+func (pkg *pkgInfo) basicOf(name string, depth int) (basicInfo, bool) {
+	if b, ok := basicKinds[name]; ok {
+		return b, true
+	}
+	ts, ok := pkg.specs[name]
+	if !ok || ts.TypeParams != nil || pkg.custom[name] || depth > 10 {
+		return basicInfo{}, false
+	}
+	ident, ok := ts.Type.(*ast.Ident)
+	if !ok {
+		return basicInfo{}, false
+	}
+	b, ok := pkg.basicOf(ident.Name, depth+1)
+	if !ts.Assign.IsValid() {
+		b.name = name
+	}
+	return b, ok
+}
+
+// isTabular reports whether the generated struct type name has fields, all
+// of them strings, booleans or numbers.
+func (pkg *pkgInfo) isTabular(name string) bool {
+	n := 0
+	for _, f := range pkg.specs[name].Type.(*ast.StructType).Fields.List {
+		if f.Tag == nil {
+			continue
+		}
+		tag, err := strconv.Unquote(f.Tag.Value)
+		if err != nil {
+			return false
+		}
+		if toon, ok := reflect.StructTag(tag).Lookup("toon"); !ok || toon == "" {
+			continue
+		}
+		ident, ok := f.Type.(*ast.Ident)
+		if !ok {
+			return false
+		}
+		if _, ok := pkg.basicOf(ident.Name, 0); !ok {
+			return false
+		}
+		n++
+	}
+	return n > 0
+}
+
+// exprString returns the source of the type expression expr.
+func exprString(expr ast.Expr) string {
+	switch x := expr.(type) {
+	case *ast.Ident:
+		return x.Name
+	case *ast.StarExpr:
+		return "*" + exprString(x.X)
+	case *ast.SelectorExpr:
+		return exprString(x.X) + "." + x.Sel.Name
+	case *ast.ArrayType:
+		if x.Len == nil {
+			return "[]" + exprString(x.Elt)
+		}
+	}
+	return ""
+}
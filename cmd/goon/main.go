@@ -0,0 +1,322 @@
+// Goon converts, formats and checks TOON documents.
+//
+// Usage:
+//
+//	goon encode [file]          convert JSON to TOON
+//	goon decode [-pretty] [file] convert TOON to JSON
+//	goon fmt [file...]          rewrite TOON files in their canonical form
+//	goon validate [file...]     check TOON files strictly
+//	goon stats [file]           compare the size of a document as TOON and JSON
+//
+// Commands read the standard input when no file is given, or when the file
+// is "-", and write to the standard output, except fmt, which rewrites the
+// files it is given in place. Flags may come before or after the files.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/roboogg133/goon/goon"
+	"github.com/roboogg133/goon/goon/tokens"
+)
+
+const usage = `usage: goon <command> [arguments]
+
+commands:
+  encode [file]            convert JSON to TOON
+  decode [-pretty] [file]  convert TOON to JSON
+  fmt [file...]            rewrite TOON files in their canonical form
+  validate [file...]       check TOON files strictly
+  stats [file]             compare the size of a document as TOON and JSON
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// A command is a subcommand being run, with the streams it reads and writes.
+type command struct {
+	name   string
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// run runs the command line args and returns the exit code: 0 on success, 1
+// when a document is invalid or can't be read and 2 on usage errors.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	cmd := &command{name: args[0], stdin: stdin, stdout: stdout, stderr: stderr}
+	flags := flag.NewFlagSet("goon "+cmd.name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	pretty := flags.Bool("pretty", false, "indent the JSON output")
+	var exec func(args []string) error
+	switch cmd.name {
+	case "encode":
+		exec = cmd.encode
+	case "decode":
+		exec = func(args []string) error { return cmd.decode(args, *pretty) }
+	case "fmt":
+		exec = cmd.format
+	case "validate":
+		exec = cmd.validate
+	case "stats":
+		exec = cmd.stats
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "goon: unknown command %q\n\n%s", cmd.name, usage)
+		return 2
+	}
+
+	files, err := parseArgs(flags, args[1:])
+	if err != nil {
+		return 2
+	}
+	if *pretty && cmd.name != "decode" {
+		fmt.Fprintf(stderr, "goon %s: -pretty only applies to decode\n", cmd.name)
+		return 2
+	}
+	if n := len(files); n > 1 && (cmd.name == "encode" || cmd.name == "decode" || cmd.name == "stats") {
+		fmt.Fprintf(stderr, "goon %s: expected at most one file, got %d\n", cmd.name, n)
+		return 2
+	}
+
+	if err := exec(files); err != nil {
+		if !errors.Is(err, errReported) {
+			fmt.Fprintf(stderr, "goon %s: %v\n", cmd.name, strings.TrimPrefix(err.Error(), "goon: "))
+		}
+		return 1
+	}
+	return 0
+}
+
+// parseArgs parses the flags of args, which may come before or after the
+// files, and returns the files. The flag package stops at the first file, so
+// the flags are parsed again after each one, up to a "--" argument, after
+// which all the arguments are files.
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var files []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := flags.Args()
+		if len(rest) == 0 {
+			return files, nil
+		}
+		if parsed := len(args) - len(rest); parsed > 0 && args[parsed-1] == "--" {
+			return append(files, rest...), nil
+		}
+		files = append(files, rest[0])
+		args = rest[1:]
+	}
+}
+
+// errReported is returned by commands that already reported their errors.
+var errReported = errors.New("errors reported")
+
+// read returns the content of the file name, or of the standard input when
+// name is empty or "-".
+func (cmd *command) read(name string) ([]byte, error) {
+	if name == "" || name == "-" {
+		return io.ReadAll(cmd.stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// input returns the content of the single optional file of args.
+func (cmd *command) input(args []string) ([]byte, error) {
+	if len(args) == 0 {
+		return cmd.read("")
+	}
+	return cmd.read(args[0])
+}
+
+func (cmd *command) encode(args []string) error {
+	data, err := cmd.input(args)
+	if err != nil {
+		return err
+	}
+	out, err := goon.FromJSON(data)
+	if err != nil {
+		return err
+	}
+	_, err = cmd.stdout.Write(append(out, '\n'))
+	return err
+}
+
+func (cmd *command) decode(args []string, pretty bool) error {
+	data, err := cmd.input(args)
+	if err != nil {
+		return err
+	}
+	out, err := goon.ToJSON(data)
+	if err != nil {
+		return err
+	}
+	if pretty {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, out, "", "  "); err != nil {
+			return err
+		}
+		out = buf.Bytes()
+	}
+	_, err = cmd.stdout.Write(append(out, '\n'))
+	return err
+}
+
+func (cmd *command) format(args []string) error {
+	if len(args) == 0 {
+		data, err := cmd.read("")
+		if err != nil {
+			return err
+		}
+		out, err := goon.Format(data, goon.FormatOptions{})
+		if err != nil {
+			return err
+		}
+		_, err = cmd.stdout.Write(out)
+		return err
+	}
+
+	failed := false
+	for _, name := range args {
+		if err := formatFile(name); err != nil {
+			fmt.Fprintf(cmd.stderr, "%s: %s\n", name, strings.TrimPrefix(err.Error(), "goon: "))
+			failed = true
+		}
+	}
+	if failed {
+		return errReported
+	}
+	return nil
+}
+
+// formatFile rewrites the file name in its canonical form, unless it already
+// is.
+func formatFile(name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	out, err := goon.Format(data, goon.FormatOptions{})
+	if err != nil || bytes.Equal(out, data) {
+		return err
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, out, info.Mode().Perm())
+}
+
+func (cmd *command) validate(args []string) error {
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+
+	failed := false
+	for _, name := range args {
+		data, err := cmd.read(name)
+		if err == nil {
+			err = validate(data)
+		}
+		if err != nil {
+			if name == "-" {
+				name = "<stdin>"
+			}
+			fmt.Fprintln(cmd.stderr, position(name, err))
+			failed = true
+		}
+	}
+	if failed {
+		return errReported
+	}
+	return nil
+}
+
+// validate checks the TOON document data strictly: its indentation must be
+// made of spaces, in multiples of the indentation of the document, as
+// Decoder.StrictIndentation requires, and its arrays must have the number of
+// elements and fields their headers declare.
+func validate(data []byte) error {
+	dec := goon.NewDecoder(bytes.NewReader(data))
+	dec.StrictIndentation()
+	var v any
+	// Documents whose root is not an object don't decode into an empty
+	// interface, so only the syntax errors of the decoder are reported.
+	var syntaxErr *goon.SyntaxError
+	if err := dec.Decode(&v); errors.As(err, &syntaxErr) {
+		return err
+	}
+
+	_, err := goon.ToJSON(data)
+	return err
+}
+
+// position formats err, found in the file name, as `name:line:column: message`,
+// or as `name:line: message` for the syntax errors whose column is unknown.
+func position(name string, err error) string {
+	var syntaxErr *goon.SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Line <= 0 {
+		return fmt.Sprintf("%s: %s", name, strings.TrimPrefix(err.Error(), "goon: "))
+	}
+
+	msg := strings.TrimPrefix(err.Error(), fmt.Sprintf("goon: line %d: ", syntaxErr.Line))
+	if syntaxErr.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", name, syntaxErr.Line, syntaxErr.Column, msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", name, syntaxErr.Line, msg)
+}
+
+func (cmd *command) stats(args []string) error {
+	data, err := cmd.input(args)
+	if err != nil {
+		return err
+	}
+
+	// The input is either JSON or TOON, converted to the other one.
+	toon := data
+	if json.Valid(data) {
+		if toon, err = goon.FromJSON(data); err != nil {
+			return err
+		}
+	}
+	r, err := tokens.CompareTOON(tokens.Approximate(), toon)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.stdout, "%-13s %8s %8s %8s\n", "format", "bytes", "tokens", "vs JSON")
+	for _, row := range []struct {
+		name string
+		size tokens.Size
+	}{
+		{"JSON", r.PrettyJSON},
+		{"JSON compact", r.JSON},
+		{"TOON", r.TOON},
+	} {
+		fmt.Fprintf(cmd.stdout, "%-13s %8d %8d %+7.1f%%\n", row.name, row.size.Bytes, row.size.Tokens, percent(row.size.Tokens, r.PrettyJSON.Tokens))
+	}
+	return nil
+}
+
+func percent(n, base int) float64 {
+	if base == 0 {
+		return 0
+	}
+	return float64(n-base) / float64(base) * 100
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runGoon runs the command line args with stdin as the standard input.
+func runGoon(t *testing.T, stdin string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	code = run(args, strings.NewReader(stdin), &out, &errOut)
+	return out.String(), errOut.String(), code
+}
+
+func TestCommands(t *testing.T) {
+	const (
+		jsonDoc = `{"name":"Ada","langs":["en","fr"],"rows":[{"id":1,"ok":true},{"id":2,"ok":false}]}`
+		toonDoc = "name : Ada\nlangs[2]: en,fr\nrows[2]{id,ok}:\n  1,true\n  2,false\n"
+	)
+
+	t.Run("encode", func(t *testing.T) {
+		out, errOut, code := runGoon(t, jsonDoc, "encode")
+		if code != 0 || out != toonDoc {
+			t.Errorf("got %d %q %q, want %q", code, out, errOut, toonDoc)
+		}
+	})
+
+	t.Run("decode", func(t *testing.T) {
+		out, errOut, code := runGoon(t, toonDoc, "decode")
+		if code != 0 || out != jsonDoc+"\n" {
+			t.Errorf("got %d %q %q, want %q", code, out, errOut, jsonDoc)
+		}
+
+		out, _, _ = runGoon(t, "a : 1", "decode", "-pretty")
+		if out != "{\n  \"a\": 1\n}\n" {
+			t.Errorf("-pretty: got %q", out)
+		}
+
+		// Flags may follow the file, unless they come after "--".
+		file := filepath.Join(t.TempDir(), "a.toon")
+		if err := os.WriteFile(file, []byte("a : 1"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		for _, args := range [][]string{{"decode", file, "-pretty"}, {"decode", "-", "-pretty"}} {
+			if out, errOut, _ := runGoon(t, "a : 1", args...); out != "{\n  \"a\": 1\n}\n" {
+				t.Errorf("%q: got %q %q", args, out, errOut)
+			}
+		}
+		if _, errOut, code := runGoon(t, "a : 1", "decode", "--", "-pretty"); code != 1 || !strings.Contains(errOut, "-pretty") {
+			t.Errorf("-pretty after --: got %d %q, want an error reading the file -pretty", code, errOut)
+		}
+	})
+
+	t.Run("fmt", func(t *testing.T) {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "doc.toon")
+		if err := os.WriteFile(name, []byte("name:   Ada\nlangs[2]:  en , fr\nrows[2]{id,ok}:\n    1,true\n    2,false"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, errOut, code := runGoon(t, "", "fmt", name); code != 0 {
+			t.Fatalf("got %d %q", code, errOut)
+		}
+		got, _ := os.ReadFile(name)
+		if string(got) != toonDoc {
+			t.Errorf("got:\n%s\nwant:\n%s", got, toonDoc)
+		}
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		for _, doc := range []string{toonDoc, "42", "[2]: a,b", "[1]:\n  - a : 1\n    b : 2"} {
+			if _, errOut, code := runGoon(t, doc, "validate"); code != 0 {
+				t.Errorf("valid document %q: got %d %q", doc, code, errOut)
+			}
+		}
+
+		for doc, want := range map[string]string{
+			"a :\n  b : 1\n   c : 2": "<stdin>:3:4: indentation of 3 spaces is not a multiple of 2",
+			"a :\n\tb : 1":           "<stdin>:2:1: tabs are not allowed in indentation",
+			"a[2]:\n  - 1\n   - 2":   "<stdin>:3:4: indentation of 3 spaces is not a multiple of 2",
+			"a[2]{x}:\n  1\n   2":    "<stdin>:3:4: indentation of 3 spaces is not a multiple of 2",
+			"a :\n  b[3]: 1,2":       "<stdin>:2: array declares 3 elements but has 2",
+			"a : 1\nb : \"open":      "<stdin>:2: invalid quoted string \"open",
+			"rows[1]{a,b}:\n  1,2,3": "<stdin>:2: row has 3 values but the header declares 2 fields",
+		} {
+			_, errOut, code := runGoon(t, doc, "validate")
+			if code != 1 || strings.TrimSpace(errOut) != want {
+				t.Errorf("%q: got %d %q, want %q", doc, code, errOut, want)
+			}
+		}
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		out, errOut, code := runGoon(t, jsonDoc, "stats")
+		if code != 0 || !strings.Contains(out, "TOON") || !strings.Contains(out, "JSON compact") {
+			t.Errorf("got %d %q %q", code, out, errOut)
+		}
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		for _, args := range [][]string{nil, {"unknown"}, {"encode", "a", "b"}, {"encode", "-pretty"}} {
+			if _, _, code := runGoon(t, "", args...); code != 2 {
+				t.Errorf("%q: got exit code %d, want 2", args, code)
+			}
+		}
+	})
+}
@@ -0,0 +1,244 @@
+package goon
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// assign stores the decoded value v into dst, converting it to the type of
+// dst. A null value, represented by an invalid v, sets dst to its zero value,
+// so pointers become nil. Otherwise nil pointers are allocated on the way
+// down, at any level, and the elements of slices and arrays are assigned one
+// by one. Numbers are converted between numeric kinds as long as they fit,
+// and strings and booleans may be stored into named types.
+func (ds *decodeState) assign(dst reflect.Value, v reflect.Value, format string) error {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	if dst.Kind() == reflect.Pointer {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return ds.assign(dst.Elem(), v, format)
+	}
+
+	if ok, err := ds.unmarshalScalar(dst, v, format); ok {
+		return err
+	}
+
+	if v.Type().AssignableTo(dst.Type()) {
+		dst.Set(v)
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Slice:
+		if v.Kind() != reflect.Slice {
+			break
+		}
+		slice := reflect.MakeSlice(dst.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if err := ds.assign(slice.Index(i), v.Index(i), format); err != nil {
+				return err
+			}
+		}
+		dst.Set(slice)
+		return nil
+
+	case reflect.Array:
+		if v.Kind() != reflect.Slice {
+			break
+		}
+		if v.Len() != dst.Len() {
+			return fmt.Errorf("goon: trying to assign %d elements to %s", v.Len(), dst.Type())
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := ds.assign(dst.Index(i), v.Index(i), format); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := intOf(v)
+		if !ok || dst.OverflowInt(n) {
+			break
+		}
+		dst.SetInt(n)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := intOf(v)
+		if !ok || n < 0 || dst.OverflowUint(uint64(n)) {
+			break
+		}
+		dst.SetUint(uint64(n))
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch v.Kind() {
+		case reflect.Int:
+			f = float64(v.Int())
+		case reflect.Float64:
+			f = v.Float()
+		}
+		if !v.CanInt() && !v.CanFloat() || dst.OverflowFloat(f) {
+			break
+		}
+		dst.SetFloat(f)
+		return nil
+
+	case reflect.String, reflect.Bool:
+		if v.Kind() == dst.Kind() {
+			dst.Set(v.Convert(dst.Type()))
+			return nil
+		}
+	}
+
+	return fmt.Errorf("goon: trying to assign %s to %s", v.Type(), dst.Type())
+}
+
+// intOf returns the decoded number v as an int64 when it holds an integral
+// value that fits, such as 42 or 1e3.
+func intOf(v reflect.Value) (int64, bool) {
+	switch v.Kind() {
+	case reflect.Int:
+		return v.Int(), true
+	case reflect.Float64:
+		f := v.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(f), true
+	}
+	return 0, false
+}
+
+// assignTable stores the rows of a tabular array into dst, which may be a
+// slice or an array of structs, maps or pointers to them, or an empty
+// interface, which receives a []map[string]any. header lists the fields of
+// the tabular header in order and path is the path of the array, used to
+// report unknown and missing fields.
+func (ds *decodeState) assignTable(dst reflect.Value, header []string, rows [][]reflect.Value, path string) error {
+	for dst.Kind() == reflect.Pointer {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
+
+	if dst.Kind() == reflect.Interface && dst.NumMethod() == 0 {
+		table := reflect.New(reflect.TypeFor[[]map[string]any]()).Elem()
+		if err := ds.assignTable(table, header, rows, path); err != nil {
+			return err
+		}
+		dst.Set(table)
+		return nil
+	}
+
+	table := dst
+	switch dst.Kind() {
+	case reflect.Slice:
+		table = reflect.MakeSlice(dst.Type(), len(rows), len(rows))
+	case reflect.Array:
+		if dst.Len() != len(rows) {
+			return fmt.Errorf("goon: trying to assign %d rows to %s", len(rows), dst.Type())
+		}
+	default:
+		return fmt.Errorf("goon: trying to assign tabular array to %s", dst.Type())
+	}
+
+	// row stands for every row of the table when its elements are structs,
+	// its keys being the fields of the header.
+	row := &object{path: path + "[]", seen: make(map[string]bool)}
+	var columns []posStruct
+	if elemType := indirectType(dst.Type().Elem()); elemType.Kind() == reflect.Struct {
+		row.fields = fieldsOf(elemType)
+		columns = make([]posStruct, len(header))
+		for i, name := range header {
+			f, exists := ds.field(row, name)
+			if !exists {
+				f.Pos = -1
+			}
+			columns[i] = f
+		}
+		for _, name := range missingFields(row.fields, func(name string) bool { return row.seen[name] }) {
+			ds.missing = append(ds.missing, joinPath(row.path, name))
+		}
+	}
+
+	for i, cells := range rows {
+		if err := ds.assignRow(table.Index(i), row, columns, header, cells); err != nil {
+			return err
+		}
+	}
+
+	if dst.Kind() == reflect.Slice {
+		dst.Set(table)
+	}
+	return nil
+}
+
+// assignRow stores the cells of a single tabular row, in the order of the
+// header, into dst. Struct rows are decoded through columns, the struct
+// fields of the header fields, with a negative Pos for unknown ones; map rows
+// get one entry per non-null cell.
+func (ds *decodeState) assignRow(dst reflect.Value, row *object, columns []posStruct, header []string, cells []reflect.Value) error {
+	for dst.Kind() == reflect.Pointer {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
+
+	if dst.Kind() == reflect.Interface && dst.NumMethod() == 0 {
+		m := reflect.ValueOf(make(map[string]any))
+		dst.Set(m)
+		dst = m
+	}
+
+	switch dst.Kind() {
+	case reflect.Struct:
+		if err := ds.applyDefaults(dst, row.fields, func(name string) bool { return row.seen[name] }); err != nil {
+			return err
+		}
+		for i, f := range columns {
+			if f.Pos < 0 {
+				continue
+			}
+			if err := ds.assign(dst.Field(f.Pos), cells[i], f.Format); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMap(dst.Type()))
+		}
+		for i, name := range header {
+			if !cells[i].IsValid() {
+				continue
+			}
+			key, err := decodeMapKey(dst.Type().Key(), name)
+			if err != nil {
+				return err
+			}
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if err := ds.assign(elem, cells[i], ""); err != nil {
+				return err
+			}
+			dst.SetMapIndex(key, elem)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("goon: trying to assign tabular row to %s", dst.Type())
+}
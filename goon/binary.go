@@ -0,0 +1,113 @@
+package goon
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Binary encodings accepted by Encoder.SetBinaryEncoding,
+// Decoder.SetBinaryEncoding and the `format` struct tag of []byte and [N]byte
+// fields. BinaryBase64 is the default.
+const (
+	BinaryBase64    = "base64"
+	BinaryBase64URL = "base64url"
+	BinaryHex       = "hex"
+)
+
+// isBytesType reports whether t is a []byte or [N]byte, which are encoded as
+// a single string instead of a list of numbers.
+func isBytesType(t reflect.Type) bool {
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8
+}
+
+// marshalBytes formats v when it holds a []byte or [N]byte and reports
+// whether it did. A nil slice is written as `null`.
+func (es *encodeState) marshalBytes(v reflect.Value, format string) (string, bool) {
+	if !v.IsValid() || !isBytesType(v.Type()) {
+		return "", false
+	}
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		return "null", true
+	}
+
+	// Element types may be named byte types, which reflect.Copy doesn't
+	// convert.
+	var b []byte
+	if v.Kind() == reflect.Slice {
+		b = v.Bytes()
+	} else {
+		b = make([]byte, v.Len())
+		for i := range b {
+			b[i] = byte(v.Index(i).Uint())
+		}
+	}
+
+	if format == "" {
+		format = es.binaryEncoding
+	}
+	switch format {
+	case BinaryHex:
+		return formatString(hex.EncodeToString(b)), true
+	case BinaryBase64URL:
+		return formatString(base64.URLEncoding.EncodeToString(b)), true
+	default:
+		return formatString(base64.StdEncoding.EncodeToString(b)), true
+	}
+}
+
+// unmarshalBytes stores the decoded scalar v into dst when dst is a []byte or
+// [N]byte and reports whether it was one of them. A null value leaves dst
+// untouched.
+func (ds *decodeState) unmarshalBytes(dst reflect.Value, v reflect.Value, format string) (bool, error) {
+	if !isBytesType(dst.Type()) {
+		return false, nil
+	}
+	if !v.IsValid() {
+		return true, nil
+	}
+
+	var s string
+	switch raw := v.Interface().(type) {
+	case string:
+		s = raw
+	case int:
+		s = strconv.Itoa(raw)
+	default:
+		return true, fmt.Errorf("goon: trying to assign %s to %s", v.Kind(), dst.Type())
+	}
+
+	if format == "" {
+		format = ds.binaryEncoding
+	}
+	var b []byte
+	var err error
+	switch format {
+	case BinaryHex:
+		b, err = hex.DecodeString(s)
+	case BinaryBase64URL:
+		b, err = base64.URLEncoding.DecodeString(s)
+	default:
+		b, err = base64.StdEncoding.DecodeString(s)
+	}
+	if err != nil {
+		return true, fmt.Errorf("goon: invalid %s data %q: %w", dst.Type(), s, err)
+	}
+
+	if dst.Kind() == reflect.Array {
+		if len(b) != dst.Len() {
+			return true, fmt.Errorf("goon: trying to assign %d bytes to %s", len(b), dst.Type())
+		}
+		for i, c := range b {
+			dst.Index(i).SetUint(uint64(c))
+		}
+		return true, nil
+	}
+
+	slice := reflect.New(dst.Type()).Elem()
+	slice.SetBytes(b)
+	dst.Set(slice)
+	return true, nil
+}
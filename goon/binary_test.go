@@ -0,0 +1,112 @@
+package goon_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/roboogg133/goon/goon"
+)
+
+type Blob struct {
+	Data  []byte  `toon:"data"`
+	Hash  [4]byte `toon:"hash" format:"hex"`
+	Token []byte  `toon:"token" format:"base64url"`
+	Empty []byte  `toon:"empty"`
+}
+
+func TestBinary(t *testing.T) {
+
+	blob := Blob{
+		Data:  []byte("hello, world"),
+		Hash:  [4]byte{0xde, 0xad, 0xbe, 0xef},
+		Token: []byte{0xfb, 0xff},
+	}
+
+	t.Run("marshal", func(t *testing.T) {
+		a, err := goon.Marshal(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := `data : "aGVsbG8sIHdvcmxk"
+hash : deadbeef
+token : "-_8="
+empty : null`
+		if string(a) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", a, want)
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		a, err := goon.Marshal(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got Blob
+		if err := goon.Unmarshal(a, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, blob) {
+			t.Errorf("got %+v, want %+v", got, blob)
+		}
+	})
+
+	t.Run("encoder option", func(t *testing.T) {
+		var buf bytes.Buffer
+		enc := goon.NewEncoder(&buf)
+		enc.SetBinaryEncoding(goon.BinaryHex)
+
+		v := struct {
+			Data []byte `toon:"data"`
+		}{Data: []byte{0x01, 0xab}}
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != "data : \"01ab\"\n" {
+			t.Errorf("got %q", buf.String())
+		}
+
+		var got struct {
+			Data []byte `toon:"data"`
+		}
+		dec := goon.NewDecoder(&buf)
+		dec.SetBinaryEncoding(goon.BinaryHex)
+		if err := dec.Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Data, v.Data) {
+			t.Errorf("got %x", got.Data)
+		}
+	})
+
+	t.Run("named byte types", func(t *testing.T) {
+		type octet byte
+		type octets struct {
+			Data  []octet   `toon:"data"`
+			Hash  [2]octet  `toon:"hash" format:"hex"`
+			Bytes namedData `toon:"bytes"`
+		}
+		v := octets{Data: []octet("hi"), Hash: [2]octet{0xbe, 0xef}, Bytes: namedData{1, 2}}
+
+		a, err := goon.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "data : aGk=\nhash : beef\nbytes : AQI="; string(a) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", a, want)
+		}
+
+		var got octets
+		if err := goon.Unmarshal(a, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, v) {
+			t.Errorf("got %+v, want %+v", got, v)
+		}
+	})
+}
+
+// namedData is a named byte slice type.
+type namedData []byte
@@ -0,0 +1,167 @@
+package goon
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"slices"
+	"strconv"
+)
+
+// Budget bounds the size of the documents written by an Encoder, to fit them
+// in a prompt. Once a row of a tabular array or an item of a list would
+// exceed the budget, it is left out along with every element written after
+// it, and the `[N]` header of each truncated array is rewritten to the number
+// of elements kept. Keys, primitive values, inline arrays and the output of
+// Marshaler implementations are always written whole, so a document may
+// still exceed its budget when these alone do. A zero field means no limit.
+type Budget struct {
+	// Bytes is the maximum size of the document in bytes, including its
+	// trailing newline.
+	Bytes int
+	// Tokens is the maximum number of tokens of the document as counted by
+	// Tokenizer, which must be set along with it.
+	Tokens    int
+	Tokenizer TokenCounter
+}
+
+// A TokenCounter counts the tokens of a text, like the tokenizer of a
+// language model. The tokenizers of the goon/tokens package implement it.
+type TokenCounter interface {
+	Count(text string) int
+}
+
+// A Summary reports the elements of arrays left out of a document to fit a
+// Budget.
+type Summary struct {
+	// Dropped maps the dotted path of each truncated array, such as `users`
+	// or `teams[].members`, to the number of elements left out of it, summed
+	// over all the lists the path goes through. The root array has an empty
+	// path. Dropped is nil when nothing was left out.
+	Dropped map[string]int
+}
+
+// Truncated reports whether any element was left out of the document.
+func (s Summary) Truncated() bool {
+	return len(s.Dropped) > 0
+}
+
+// Paths returns the paths of the truncated arrays, sorted.
+func (s Summary) Paths() []string {
+	return slices.Sorted(maps.Keys(s.Dropped))
+}
+
+var errNoTokenizer = errors.New("goon: token budget without a tokenizer")
+
+// SetBudget sets the budget of the documents written by the encoder. The
+// summary of the elements left out of the last document is returned by
+// Summary.
+func (enc *Encoder) SetBudget(budget Budget) {
+	enc.opts.budget = budget
+}
+
+// Summary returns the summary of the elements left out of the document
+// written by the last call to Encode.
+func (enc *Encoder) Summary() Summary {
+	return enc.summary
+}
+
+// MarshalBudget is like Marshal, but leaves out rows and list items to fit
+// the document in budget as an Encoder with SetBudget does. It returns the
+// summary of the elements left out.
+func MarshalBudget(v any, budget Budget) ([]byte, Summary, error) {
+	es := newEncodeState(encOpts{budget: budget})
+	defer es.release()
+
+	if err := es.marshal(v); err != nil {
+		return nil, Summary{}, err
+	}
+	return bytes.Clone(es.buf), es.summary(), nil
+}
+
+// A drop records the elements left out of the array at path.
+type drop struct {
+	path string
+	n    int
+}
+
+// An elemMark is the state of a document before an element of an array is
+// written, restored when the element doesn't fit the budget.
+type elemMark struct {
+	len, used, counted, drops int
+	// count is the offset of the length in the header of the array, index
+	// the index of the element and n the length of the array.
+	count, index, n int
+}
+
+// budgeted reports whether the document has a budget.
+func (es *encodeState) budgeted() bool {
+	return es.budget.Bytes > 0 || es.budget.Tokens > 0
+}
+
+// mark returns the state of the document before writing the element index of
+// the array of length n, whose length is written at es.buf[count:]. It
+// reports false, truncating the array, when the budget was already exhausted
+// by a previous element.
+func (es *encodeState) mark(count, index, n int) (elemMark, bool) {
+	m := elemMark{len(es.buf), es.used, es.counted, len(es.drops), count, index, n}
+	if es.exhausted {
+		es.truncate(m)
+		return m, false
+	}
+	return m, true
+}
+
+// fits reports whether the element written since m fits the budget. When it
+// doesn't, the element is removed and the array is truncated before it.
+func (es *encodeState) fits(m elemMark) bool {
+	if !es.budgeted() || es.withinBudget() {
+		return true
+	}
+	es.buf = es.buf[:m.len]
+	es.used, es.counted, es.drops = m.used, m.counted, es.drops[:m.drops]
+	es.exhausted = true
+	es.truncate(m)
+	return false
+}
+
+// withinBudget reports whether the document written so far fits the budget.
+// Tokens are counted incrementally, from the end of the text counted by the
+// previous call.
+func (es *encodeState) withinBudget() bool {
+	if b := es.budget.Bytes; b > 0 && len(es.buf)-es.start > b {
+		return false
+	}
+	if b := es.budget.Tokens; b > 0 {
+		es.used += es.budget.Tokenizer.Count(string(es.buf[es.counted:]))
+		es.counted = len(es.buf)
+		if es.used > b {
+			return false
+		}
+	}
+	return true
+}
+
+// truncate rewrites the length of the array of m to the index of its
+// element, and records the elements left out.
+func (es *encodeState) truncate(m elemMark) {
+	old := len(strconv.Itoa(m.n))
+	digits := strconv.AppendInt(nil, int64(m.index), 10)
+	es.buf = slices.Replace(es.buf, m.count, m.count+old, digits...)
+	if es.counted > m.count {
+		es.counted += len(digits) - old
+	}
+	es.drops = append(es.drops, drop{es.path, m.n - m.index})
+}
+
+// summary returns the summary of the elements left out of the document.
+func (es *encodeState) summary() Summary {
+	if len(es.drops) == 0 {
+		return Summary{}
+	}
+	s := Summary{Dropped: make(map[string]int)}
+	for _, d := range es.drops {
+		s.Dropped[d.path] += d.n
+	}
+	return s
+}
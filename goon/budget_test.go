@@ -0,0 +1,143 @@
+package goon_test
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/roboogg133/goon/goon"
+)
+
+func TestBudget(t *testing.T) {
+	type member struct {
+		ID   int    `toon:"id"`
+		Name string `toon:"name"`
+	}
+	type team struct {
+		Name    string   `toon:"name"`
+		Members []member `toon:"members"`
+	}
+	members := []member{{1, "Ada"}, {2, "Alan"}, {3, "Grace"}}
+	v := map[string]any{
+		"members": members,
+		"teams":   []team{{"core", members[:2]}, {"docs", members[2:]}},
+	}
+
+	tests := []struct {
+		name    string
+		budget  goon.Budget
+		want    string
+		dropped map[string]int
+	}{
+		{"no budget", goon.Budget{}, `members[3]{id,name}:
+  1,Ada
+  2,Alan
+  3,Grace
+teams[2]:
+  - name : core
+    members[2]{id,name}:
+      1,Ada
+      2,Alan
+  - name : docs
+    members[1]{id,name}:
+      3,Grace`, nil},
+		{"rows", goon.Budget{Bytes: 40}, `members[2]{id,name}:
+  1,Ada
+  2,Alan
+teams[0]:`, map[string]int{"members": 1, "teams": 2}},
+		{"no rows", goon.Budget{Bytes: 10}, `members[0]{id,name}:
+teams[0]:`, map[string]int{"members": 3, "teams": 2}},
+		{"nested rows", goon.Budget{Bytes: 115}, `members[3]{id,name}:
+  1,Ada
+  2,Alan
+  3,Grace
+teams[1]:
+  - name : core
+    members[1]{id,name}:
+      1,Ada`, map[string]int{"teams": 1, "teams[].members": 1}},
+		{"tokens", goon.Budget{Tokens: 8, Tokenizer: lineCounter{}}, `members[3]{id,name}:
+  1,Ada
+  2,Alan
+  3,Grace
+teams[1]:
+  - name : core
+    members[1]{id,name}:
+      1,Ada`, map[string]int{"teams": 1, "teams[].members": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, summary, err := goon.MarshalBudget(v, tt.budget)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", data, tt.want)
+			}
+			if !maps.Equal(summary.Dropped, tt.dropped) {
+				t.Errorf("dropped %v, want %v", summary.Dropped, tt.dropped)
+			}
+			if summary.Truncated() != (tt.dropped != nil) {
+				t.Errorf("Truncated() = %v", summary.Truncated())
+			}
+			if _, err := goon.ToJSON(data); err != nil {
+				t.Errorf("truncated document is invalid: %v", err)
+			}
+		})
+	}
+
+	t.Run("list items", func(t *testing.T) {
+		items := []any{"a", map[string]any{"x": 1}, "b", "c"}
+		data, summary, err := goon.MarshalBudget(items, goon.Budget{Bytes: 21})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "[2]:\n  - a\n  - x : 1"; string(data) != want {
+			t.Errorf("got %q, want %q", data, want)
+		}
+		if got := summary.Paths(); len(got) != 1 || got[0] != "" || summary.Dropped[""] != 2 {
+			t.Errorf("dropped %v, want 2 at the root", summary.Dropped)
+		}
+	})
+
+	t.Run("encoder", func(t *testing.T) {
+		var buf bytes.Buffer
+		enc := goon.NewEncoder(&buf)
+		enc.SetBudget(goon.Budget{Bytes: 40})
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		if got := enc.Summary().Dropped["members"]; got != 1 {
+			t.Errorf("dropped %d members, want 1", got)
+		}
+		if err := enc.Encode(members[:1]); err != nil {
+			t.Fatal(err)
+		}
+		if enc.Summary().Truncated() {
+			t.Errorf("summary of a document within budget: %v", enc.Summary().Dropped)
+		}
+		if !strings.HasSuffix(buf.String(), "[1]{id,name}:\n  1,Ada\n") {
+			t.Errorf("got:\n%s", buf.String())
+		}
+	})
+
+	t.Run("no tokenizer", func(t *testing.T) {
+		_, _, err := goon.MarshalBudget(v, goon.Budget{Tokens: 10})
+		if err == nil || !strings.HasPrefix(err.Error(), "goon: ") {
+			t.Errorf("err = %v", err)
+		}
+		var syntaxErr *goon.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			t.Errorf("err = %v", err)
+		}
+	})
+}
+
+// lineCounter counts a token per line.
+type lineCounter struct{}
+
+func (lineCounter) Count(text string) int {
+	return strings.Count(text, "\n")
+}
@@ -0,0 +1,214 @@
+package goon
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// tagOptions is the string following the name in a `toon` struct tag, a
+// comma-separated list of options such as `required`.
+type tagOptions string
+
+// parseTag splits a `toon` struct tag into its name and its options.
+func parseTag(tag string) (string, tagOptions) {
+	name, opts, _ := strings.Cut(tag, ",")
+	return name, tagOptions(opts)
+}
+
+// Get returns the value of the option `name=value`.
+func (o tagOptions) Get(name string) (string, bool) {
+	for opt := range strings.SplitSeq(string(o), ",") {
+		if value, ok := strings.CutPrefix(opt, name+"="); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Contains reports whether the options include the flag name.
+func (o tagOptions) Contains(name string) bool {
+	for opt := range strings.SplitSeq(string(o), ",") {
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldName returns the key of a struct field and its tag options, or false
+// when the field has no `toon` tag and is therefore skipped. A tag with an
+// empty name, such as `toon:",required"`, uses the name of the Go field.
+func fieldName(f reflect.StructField) (string, tagOptions, bool) {
+	tag, ok := f.Tag.Lookup("toon")
+	if !ok || tag == "" {
+		return "", "", false
+	}
+
+	name, opts := parseTag(tag)
+	if name == "" {
+		name = f.Name
+	}
+	return name, opts, true
+}
+
+// posStructCache maps a struct reflect.Type to its map[string]posStruct.
+var posStructCache sync.Map
+
+// fieldsOf returns the decodable fields of the struct type t by key. The
+// result is cached per type and must not be modified.
+func fieldsOf(t reflect.Type) map[string]posStruct {
+	if fields, ok := posStructCache.Load(t); ok {
+		return fields.(map[string]posStruct)
+	}
+
+	fields := make(map[string]posStruct)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+
+		def, hasDef := f.Tag.Lookup("default")
+		var aliases []string
+		if alias, ok := opts.Get("alias"); ok {
+			aliases = strings.Split(alias, "|")
+		}
+		fields[name] = posStruct{
+			Name:       name,
+			Pos:        i,
+			Format:     f.Tag.Get("format"),
+			Required:   opts.Contains("required"),
+			Default:    def,
+			HasDefault: hasDef,
+			Aliases:    aliases,
+		}
+	}
+
+	cached, _ := posStructCache.LoadOrStore(t, fields)
+	return cached.(map[string]posStruct)
+}
+
+// A field is a struct field encoded by Marshal.
+type field struct {
+	// key is the name of the field formatted as a TOON key.
+	key       string
+	index     int
+	omitEmpty bool
+	format    string
+	// primitive reports whether the values of the field are always strings,
+	// numbers, booleans or scalars, so that they fit in a tabular row.
+	primitive bool
+}
+
+// fieldCache maps a struct reflect.Type to its []field.
+var fieldCache sync.Map
+
+// typeFields returns the fields of the struct type t that are encoded, in
+// the order they are declared. The result is cached per type.
+func typeFields(t reflect.Type) []field {
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.([]field)
+	}
+
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+		fields = append(fields, field{
+			key:       formatKey(name),
+			index:     i,
+			omitEmpty: f.Tag.Get("omitempty") != "",
+			format:    f.Tag.Get("format"),
+			primitive: isScalarType(f.Type) || isPrimitiveKind(f.Type.Kind()),
+		})
+	}
+
+	cached, _ := fieldCache.LoadOrStore(t, fields)
+	return cached.([]field)
+}
+
+// lookupField returns the field for the key, matching field names first and
+// aliases second. With foldCase, it then falls back to matching names and
+// aliases ignoring case; when several fields match, the first declared wins.
+func lookupField(fields map[string]posStruct, key string, foldCase bool) (posStruct, bool) {
+	if f, ok := fields[key]; ok {
+		return f, true
+	}
+
+	match := func(equal func(a, b string) bool) (posStruct, bool) {
+		var found posStruct
+		ok := false
+		for name, f := range fields {
+			if ok && f.Pos > found.Pos {
+				continue
+			}
+			if equal(name, key) || slices.ContainsFunc(f.Aliases, func(alias string) bool { return equal(alias, key) }) {
+				found, ok = f, true
+			}
+		}
+		return found, ok
+	}
+
+	if f, ok := match(func(a, b string) bool { return a == b }); ok {
+		return f, true
+	}
+	if foldCase {
+		return match(strings.EqualFold)
+	}
+	return posStruct{}, false
+}
+
+// missingFields returns the names of the required fields that are not in
+// seen, in the order they are declared.
+func missingFields(fields map[string]posStruct, seen func(name string) bool) []string {
+	var missing []posStruct
+	for name, f := range fields {
+		if f.Required && !seen(name) {
+			missing = append(missing, f)
+		}
+	}
+	slices.SortFunc(missing, func(a, b posStruct) int {
+		return a.Pos - b.Pos
+	})
+
+	names := make([]string, len(missing))
+	for i, f := range missing {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// joinPath appends the key name to the dotted path of its parent object.
+func joinPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// A FieldError reports the keys of a document that don't match the fields of
+// the struct it is decoded into: unknown keys, when the Decoder disallows
+// them, and missing keys of fields tagged `required`. Keys are dotted paths
+// from the root of the document, where `[]` stands for the rows of a tabular
+// array.
+type FieldError struct {
+	Unknown []string
+	Missing []string
+}
+
+func (e *FieldError) Error() string {
+	var parts []string
+	if len(e.Unknown) > 0 {
+		parts = append(parts, "unknown keys "+strings.Join(e.Unknown, ", "))
+	}
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing required keys "+strings.Join(e.Missing, ", "))
+	}
+	return "goon: " + strings.Join(parts, "; ")
+}
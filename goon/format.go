@@ -0,0 +1,39 @@
+package goon
+
+import "bytes"
+
+// FormatOptions configures the output of Format.
+type FormatOptions struct {
+	// Indent is the number of spaces per nesting level. Zero or less means
+	// two spaces, as written by Marshal.
+	Indent int
+}
+
+// Format rewrites the TOON document src in its canonical form, the document
+// Marshal writes for the same value: `key : value` spacing, strings quoted
+// only when needed, arrays as inline, tabular or list arrays depending on
+// their elements, and consistent indentation. The keys of objects keep their
+// order and numbers are written as they appear. The result ends with a
+// newline, unless the document is empty.
+func Format(src []byte, opts FormatOptions) ([]byte, error) {
+	data, err := ToJSON(src)
+	if err != nil {
+		return nil, err
+	}
+	v, err := parseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	es := newEncodeState(encOpts{indent: opts.Indent})
+	defer es.release()
+
+	if err := es.marshal(v); err != nil {
+		return nil, err
+	}
+	out := bytes.Clone(es.buf)
+	if len(out) > 0 {
+		out = append(out, '\n')
+	}
+	return out, nil
+}
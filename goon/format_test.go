@@ -0,0 +1,79 @@
+package goon_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/roboogg133/goon/goon"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"spacing", "a: 1\nb :   x\nc:true\n", "a : 1\nb : x\nc : true\n"},
+		{"quoting", "s : \"hello\"\nq : \"007\"\nt : \"true\"\n", "s : hello\nq : \"007\"\nt : \"true\"\n"},
+		{"numbers", "n : 1.50\nbig : 12345678901234567890\ne : 1e-7\n", "n : 1.50\nbig : 12345678901234567890\ne : 1e-7\n"},
+		{"key order", "z : 1\na : 2\nm : 3\n", "z : 1\na : 2\nm : 3\n"},
+		{"indentation", "obj:\n    x: 1\n    inner:\n        y: 2\n", "obj :\n  x : 1\n  inner :\n    y : 2\n"},
+		{"list of primitives", "list[2]:\n  - 1\n  - two\n", "list[2]: 1,two\n"},
+		{"list of uniform objects", "a[2]:\n  - x: 1\n    y: 2\n  - x: 3\n    y: 4\n", "a[2]{x,y}:\n  1,2\n  3,4\n"},
+		{"root array", "[2]{a,b}:\n  1,2\n  3,4", "[2]{a,b}:\n  1,2\n  3,4\n"},
+		{"root primitive", "42", "42\n"},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := goon.Format([]byte(tt.src), goon.FormatOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			again, err := goon.Format(got, goon.FormatOptions{})
+			if err != nil || !bytes.Equal(again, got) {
+				t.Errorf("formatting again gives:\n%s\nerr: %v", again, err)
+			}
+		})
+	}
+
+	t.Run("indent", func(t *testing.T) {
+		got, err := goon.Format([]byte("obj :\n  x : 1\n  list[1]:\n    - a : 1\n      b[0]:\n"), goon.FormatOptions{Indent: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "obj :\n    x : 1\n    list[1]:\n        - a : 1\n            b[0]:\n"
+		if string(got) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("fixtures", func(t *testing.T) {
+		for _, name := range []string{"object.toon", "mixedList.toon", "tooncsv.toon"} {
+			data, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := goon.Format(data, goon.FormatOptions{})
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			before, _ := goon.ToJSON(data)
+			after, _ := goon.ToJSON(got)
+			if !bytes.Equal(before, after) {
+				t.Errorf("%s: formatting changed the value from %s to %s", name, before, after)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := goon.Format([]byte("a[2]: 1\n"), goon.FormatOptions{}); err == nil {
+			t.Error("Format of an invalid document succeeded")
+		}
+	})
+}
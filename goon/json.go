@@ -0,0 +1,504 @@
+package goon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FromJSON converts the JSON document data into TOON, the way Marshal encodes
+// the same value. The keys of objects keep the order they have in data and
+// numbers are written as they appear, without going through float64.
+//
+// FromJSON does not stream: data is read token by token, but the whole value
+// is held in memory before any TOON is written, since whether an array is
+// written as a table depends on all of its elements.
+func FromJSON(data []byte) ([]byte, error) {
+	v, err := parseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return Marshal(v)
+}
+
+// parseJSON reads the JSON document data as a value of the types returned by
+// readJSON.
+func parseJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := readJSON(dec)
+	if err != nil {
+		return nil, fmt.Errorf("goon: invalid JSON: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		if err == nil {
+			err = errors.New("data after top-level value")
+		}
+		return nil, fmt.Errorf("goon: invalid JSON: %w", err)
+	}
+	return v, nil
+}
+
+// readJSON reads the next JSON value of dec: a *jsonObject, a jsonArray, a
+// string, a json.Number, a bool or nil.
+func readJSON(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		obj := &jsonObject{index: make(map[string]int)}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := readJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.set(key.(string), v)
+		}
+		_, err := dec.Token()
+		return obj, err
+
+	case json.Delim('['):
+		arr := jsonArray{}
+		for dec.More() {
+			v, err := readJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		_, err := dec.Token()
+		return arr, err
+	}
+	return tok, nil
+}
+
+// A jsonObject is a JSON object with its keys in the order they appear.
+type jsonObject struct {
+	keys   []string
+	values []any
+	index  map[string]int
+}
+
+// set adds the key name, or replaces its value when it is repeated, like
+// encoding/json does.
+func (o *jsonObject) set(name string, v any) {
+	if i, ok := o.index[name]; ok {
+		o.values[i] = v
+		return
+	}
+	o.index[name] = len(o.keys)
+	o.keys = append(o.keys, name)
+	o.values = append(o.values, v)
+}
+
+func (o jsonObject) entries() []entry {
+	out := make([]entry, len(o.keys))
+	for i, name := range o.keys {
+		out[i] = entry{Name: formatKey(name), Value: reflect.ValueOf(o.values[i])}
+	}
+	return out
+}
+
+// A jsonArray is a JSON array. Unlike other []any values, it is written on a
+// single line when its elements are all primitives.
+type jsonArray []any
+
+var (
+	jsonObjectType = reflect.TypeFor[jsonObject]()
+	jsonArrayType  = reflect.TypeFor[jsonArray]()
+	jsonNumberType = reflect.TypeFor[json.Number]()
+	anySliceType   = reflect.TypeFor[[]any]()
+)
+
+// jsonEncoder returns the encoder of the types JSON documents are decoded
+// into by FromJSON, or nil for other types.
+func jsonEncoder(t reflect.Type) *typeEncoder {
+	switch t {
+	case jsonObjectType:
+		return &typeEncoder{objectShape, encodeJSONObject}
+	case jsonArrayType:
+		return &typeEncoder{arrayShape, encodeJSONArray}
+	case jsonNumberType:
+		return &typeEncoder{primitiveShape, encodeJSONNumber}
+	}
+	return nil
+}
+
+func encodeJSONObject(es *encodeState, v reflect.Value, _ string) error {
+	if err := es.enter(v); err != nil {
+		return err
+	}
+	defer es.leave(v)
+
+	for _, e := range v.Interface().(jsonObject).entries() {
+		if err := es.encodeKey(e.Name, indirectValue(e.Value), ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeJSONArray(es *encodeState, v reflect.Value, format string) error {
+	arr := v.Interface().(jsonArray)
+	if len(arr) == 0 || slices.ContainsFunc(arr, func(e any) bool { return !es.isPrimitive(reflect.ValueOf(e)) }) {
+		return encoderOf(anySliceType).encode(es, v.Convert(anySliceType), format)
+	}
+
+	es.buf = append(es.buf, '[')
+	es.buf = strconv.AppendInt(es.buf, int64(len(arr)), 10)
+	es.buf = append(es.buf, ']')
+	return es.encodeInline(v)
+}
+
+// encodeJSONNumber writes a json.Number as is, or as a string when it is not
+// a valid number.
+func encodeJSONNumber(es *encodeState, v reflect.Value, _ string) error {
+	if s := v.String(); isNumber(s) {
+		es.buf = append(es.buf, s...)
+	} else {
+		es.buf = append(es.buf, formatString(s)...)
+	}
+	return nil
+}
+
+// ToJSON converts the TOON document data into compact JSON. The keys of
+// objects keep the order they have in data and numbers are written as they
+// appear, without going through float64.
+//
+// ToJSON does not stream either: the lines of data are read up front, and
+// the JSON is returned once the whole document is converted.
+func ToJSON(data []byte) ([]byte, error) {
+	c := &jsonConverter{}
+	if err := c.readLines(data); err != nil {
+		return nil, err
+	}
+	if len(c.lines) == 0 {
+		return []byte("{}"), nil
+	}
+
+	first := c.lines[0]
+	parts, err := splitKeyValue(first.text)
+	switch {
+	case err == nil && strings.HasPrefix(first.text, "["):
+		var h arrayHeader
+		var ok bool
+		if h, ok, err = parseArrayHeader(strings.TrimSpace(parts[0]), first.num); err != nil {
+			break
+		}
+		if !ok || h.key != "" {
+			return nil, &SyntaxError{msg: "invalid root array header", Line: first.num}
+		}
+		c.pos++
+		err = c.array(h, strings.TrimSpace(parts[1]), first.depth+1)
+	case err != nil && len(c.lines) == 1:
+		c.pos++
+		err = c.scalar(first.text)
+	default:
+		err = c.object(first.depth)
+	}
+	if err != nil {
+		return nil, c.lineError(err)
+	}
+	if c.pos < len(c.lines) {
+		return nil, &SyntaxError{msg: "unexpected line after the end of the document", Line: c.lines[c.pos].num}
+	}
+	return c.buf, nil
+}
+
+// A jsonConverter writes the JSON form of the lines of a TOON document.
+type jsonConverter struct {
+	lines []jsonLine
+	pos   int
+	buf   []byte
+}
+
+// A jsonLine is a non-blank line of a TOON document, trimmed, along with its
+// nesting depth and line number.
+type jsonLine struct {
+	text  string
+	depth int
+	num   int
+}
+
+func (c *jsonConverter) readLines(data []byte) error {
+	indent := detectIndent(data)
+	scanner := newLineScanner(data, 0)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		c.lines = append(c.lines, jsonLine{strings.TrimSpace(text), calcIndent(text) / indent, scanner.line})
+	}
+	return scanner.Err()
+}
+
+// lineError reports the errors about the content of the last line read, such
+// as an invalid quoted string, as SyntaxErrors locating that line.
+func (c *jsonConverter) lineError(err error) error {
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) || c.pos == 0 {
+		return err
+	}
+	return &SyntaxError{msg: strings.TrimPrefix(err.Error(), "goon: "), Line: c.lines[c.pos-1].num}
+}
+
+// object writes the object whose keys are the lines at depth, starting at
+// the current line.
+func (c *jsonConverter) object(depth int) error {
+	c.buf = append(c.buf, '{')
+	for n := 0; c.pos < len(c.lines); n++ {
+		line := c.lines[c.pos]
+		if line.depth < depth {
+			break
+		}
+		if line.depth > depth {
+			return &SyntaxError{msg: "unexpected indentation", Line: line.num}
+		}
+		if n > 0 {
+			c.buf = append(c.buf, ',')
+		}
+		if err := c.member(line, depth); err != nil {
+			return err
+		}
+	}
+	c.buf = append(c.buf, '}')
+	return nil
+}
+
+// member writes the key of the current line, at depth, and its value.
+func (c *jsonConverter) member(line jsonLine, depth int) error {
+	c.pos++
+	parts, err := splitKeyValue(line.text)
+	if err != nil {
+		return &SyntaxError{msg: "missing ':' after key", Line: line.num}
+	}
+	key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	h, isArray, err := parseArrayHeader(key, line.num)
+	if err != nil {
+		return err
+	}
+	if isArray {
+		key = h.key
+	}
+	name, err := parseKey(key)
+	if err != nil {
+		return err
+	}
+
+	c.buf = appendJSONString(c.buf, name)
+	c.buf = append(c.buf, ':')
+	switch {
+	case isArray:
+		return c.array(h, value, depth+1)
+	case value == "":
+		return c.object(depth + 1)
+	}
+	return c.scalar(value)
+}
+
+// array writes the array whose header is h, followed on its line by value.
+// The rows or items of the array are the lines at depth.
+func (c *jsonConverter) array(h arrayHeader, value string, depth int) error {
+	c.buf = append(c.buf, '[')
+	switch {
+	case h.tabular:
+		fields, err := splitDelimited(h.fields, h.delim)
+		if err != nil {
+			return err
+		}
+		for i, f := range fields {
+			if fields[i], err = parseKey(f); err != nil {
+				return err
+			}
+		}
+		for i := range h.length {
+			line, err := c.element(depth, h.length, i)
+			if err != nil {
+				return err
+			}
+			c.pos++
+			cells, err := splitDelimited(line.text, h.delim)
+			if err != nil {
+				return err
+			}
+			if len(cells) != len(fields) {
+				return &SyntaxError{msg: fmt.Sprintf("row has %d values but the header declares %d fields", len(cells), len(fields)), Line: line.num}
+			}
+			if i > 0 {
+				c.buf = append(c.buf, ',')
+			}
+			c.buf = append(c.buf, '{')
+			for j, cell := range cells {
+				if j > 0 {
+					c.buf = append(c.buf, ',')
+				}
+				c.buf = appendJSONString(c.buf, fields[j])
+				c.buf = append(c.buf, ':')
+				if cell = strings.TrimSpace(cell); cell == "" {
+					cell = "null"
+				}
+				if err := c.scalar(cell); err != nil {
+					return err
+				}
+			}
+			c.buf = append(c.buf, '}')
+		}
+
+	case value != "":
+		values, err := splitDelimited(value, h.delim)
+		if err != nil {
+			return err
+		}
+		if len(values) != h.length {
+			return lengthMismatch(c.lines[c.pos-1].num, h.length, len(values))
+		}
+		for i, v := range values {
+			if i > 0 {
+				c.buf = append(c.buf, ',')
+			}
+			if v = strings.TrimSpace(v); v == "" {
+				v = `""`
+			}
+			if err := c.scalar(v); err != nil {
+				return err
+			}
+		}
+
+	default:
+		for i := range h.length {
+			line, err := c.element(depth, h.length, i)
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				c.buf = append(c.buf, ',')
+			}
+			if err := c.item(line, depth); err != nil {
+				return err
+			}
+		}
+	}
+	c.buf = append(c.buf, ']')
+	return nil
+}
+
+// element returns the current line, the element i of an array of n elements
+// at depth, failing when the array ends early.
+func (c *jsonConverter) element(depth, n, i int) (jsonLine, error) {
+	if c.pos >= len(c.lines) || c.lines[c.pos].depth != depth {
+		num := c.lines[c.pos-1].num
+		if c.pos < len(c.lines) {
+			num = c.lines[c.pos].num
+		}
+		return jsonLine{}, &SyntaxError{msg: fmt.Sprintf("unexpected end of array: array declares %d elements but has %d", n, i), Line: num}
+	}
+	return c.lines[c.pos], nil
+}
+
+// item writes the list item of the current line, found at depth. What
+// follows the `- ` marker is nested one level below it.
+func (c *jsonConverter) item(line jsonLine, depth int) error {
+	rest, ok := strings.CutPrefix(line.text, "-")
+	if !ok || rest != "" && rest[0] != ' ' {
+		return &SyntaxError{msg: "expected a list item", Line: line.num}
+	}
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		c.pos++
+		c.buf = append(c.buf, "{}"...)
+		return nil
+	}
+
+	parts, err := splitKeyValue(rest)
+	switch {
+	case err != nil:
+		c.pos++
+		return c.scalar(rest)
+	case strings.HasPrefix(rest, "["):
+		h, ok, err := parseArrayHeader(strings.TrimSpace(parts[0]), line.num)
+		if err != nil {
+			return err
+		}
+		if !ok || h.key != "" {
+			return &SyntaxError{msg: "invalid array header", Line: line.num}
+		}
+		c.pos++
+		return c.array(h, strings.TrimSpace(parts[1]), depth+1)
+	}
+
+	// The first key of an object continues the line of the marker; the
+	// line is read again as if it started at the depth of the others.
+	c.lines[c.pos] = jsonLine{rest, depth + 1, line.num}
+	return c.object(depth + 1)
+}
+
+// scalar writes the TOON scalar s.
+func (c *jsonConverter) scalar(s string) error {
+	switch {
+	case s == "null" || s == "true" || s == "false" || isNumber(s):
+		c.buf = append(c.buf, s...)
+	case strings.HasPrefix(s, "\""):
+		str, err := unquote(s)
+		if err != nil {
+			return err
+		}
+		c.buf = appendJSONString(c.buf, str)
+	default:
+		c.buf = appendJSONString(c.buf, s)
+	}
+	return nil
+}
+
+// appendJSONString appends s to dst as a JSON string. Invalid UTF-8 is
+// replaced by U+FFFD, as encoding/json does.
+func appendJSONString(dst []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	dst = append(dst, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				dst = append(dst, '\\', c)
+			case c == '\n':
+				dst = append(dst, `\n`...)
+			case c == '\r':
+				dst = append(dst, `\r`...)
+			case c == '\t':
+				dst = append(dst, `\t`...)
+			case c < 0x20:
+				dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			default:
+				dst = append(dst, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, "\ufffd"...)
+		} else {
+			dst = append(dst, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(dst, '"')
+}
@@ -0,0 +1,92 @@
+package goon_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/roboogg133/goon/goon"
+)
+
+func TestJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		json string
+		toon string
+	}{
+		{"key order", `{"b":1,"a":{"z":true,"y":null},"c":"x"}`, "b : 1\na :\n  z : true\n  y : null\nc : x"},
+		{"numbers", `{"big":123456789012345678901234567890,"f":1.50,"e":-1E+5,"z":-0}`, "big : 123456789012345678901234567890\nf : 1.50\ne : -1E+5\nz : -0"},
+		{"strings", `{"s":"a, b","n":"42","e":"","q":"say \"hi\"\n","k: v":"null"}`, "s : \"a, b\"\nn : \"42\"\ne : \"\"\nq : \"say \\\"hi\\\"\\n\"\n\"k: v\" : \"null\""},
+		{"inline arrays", `{"tags":["a","b",1,true,null],"empty":[]}`, "tags[5]: a,b,1,true,null\nempty[0]:"},
+		{"tabular arrays", `{"rows":[{"id":1,"name":"Ada"},{"id":2,"name":null}]}`, "rows[2]{id,name}:\n  1,Ada\n  2,null"},
+		{"lists", `{"items":[1,{"a":1,"b":{"c":2}},[1,2],[{"x":1},{"y":2}],{}]}`, "items[5]:\n  - 1\n  - a : 1\n    b :\n      c : 2\n  - [2]: 1,2\n  - [2]:\n    - x : 1\n    - y : 2\n  -"},
+		{"nested tables", `[{"rows":[{"a":1},{"a":2}],"n":1}]`, "[1]:\n  - rows[2]{a}:\n      1\n      2\n    n : 1"},
+		{"root array", `[{"a":1},{"a":2}]`, "[2]{a}:\n  1\n  2"},
+		{"root string", `"hello"`, "hello"},
+		{"root number", `3.25`, "3.25"},
+		{"empty object", `{}`, ""},
+		{"empty nested object", `{"a":{},"b":1}`, "a :\nb : 1"},
+		{"unicode", `{"ключ":"значение ☃"}`, "\"ключ\" : значение ☃"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			toon, err := goon.FromJSON([]byte(tc.json))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(toon) != tc.toon {
+				t.Errorf("FromJSON:\n%s\nwant:\n%s", toon, tc.toon)
+			}
+
+			back, err := goon.ToJSON(toon)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(back) != tc.json {
+				t.Errorf("ToJSON: %s\nwant: %s", back, tc.json)
+			}
+		})
+	}
+
+	t.Run("matches Unmarshal", func(t *testing.T) {
+		data := []byte("name : Ada\nscores[3]: 1,2.5,3\nfriends[2]{name,age}:\n  Bob,30\n  Eve,41\nmeta :\n  tags[2]: x,\"y, z\"")
+		got, err := goon.ToJSON(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fromJSON, fromTOON any
+		if err := json.Unmarshal(got, &fromJSON); err != nil {
+			t.Fatalf("invalid JSON %s: %v", got, err)
+		}
+		if err := goon.Unmarshal(data, &fromTOON); err != nil {
+			t.Fatal(err)
+		}
+		a, _ := json.Marshal(fromJSON)
+		b, _ := json.Marshal(fromTOON)
+		if !bytes.Equal(a, b) {
+			t.Errorf("ToJSON: %s\nUnmarshal: %s", a, b)
+		}
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		for _, data := range []string{`{"a":`, `{"a":1} {}`, `[1,]`, ``} {
+			if _, err := goon.FromJSON([]byte(data)); err == nil {
+				t.Errorf("FromJSON(%q): expected an error", data)
+			}
+		}
+	})
+
+	t.Run("invalid TOON", func(t *testing.T) {
+		for _, data := range []string{
+			"a[3]: 1,2",
+			"a[2]:\n  - 1",
+			"a[2]{x,y}:\n  1,2\n  3",
+			"a : 1\n    b : 2",
+			"a : \"unterminated",
+			"[1]: 1\nb : 2",
+		} {
+			if _, err := goon.ToJSON([]byte(data)); err == nil {
+				t.Errorf("ToJSON(%q): expected an error", data)
+			}
+		}
+	})
+}
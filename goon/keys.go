@@ -0,0 +1,235 @@
+package goon
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// formatKey renders name as a TOON key. Names that are not valid unquoted
+// keys, a letter or underscore followed by letters, digits, underscores and
+// dots, are quoted.
+func formatKey(name string) string {
+	if isBareKey(name) {
+		return name
+	}
+	return quote(name)
+}
+
+func isBareKey(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		case i > 0 && (isDigit(c) || c == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// parseKey decodes a TOON key, which is either bare or a quoted string.
+func parseKey(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "\"") {
+		return unquote(s)
+	}
+	return s, nil
+}
+
+// An arrayHeader is the key part of an array line, such as `key[N]`,
+// `"a key"[N|]` or `key[N]{a,b}`.
+type arrayHeader struct {
+	// key is the key of the array as written, possibly quoted.
+	key    string
+	length int
+	// delim is the delimiter of the values, `,` unless the length is
+	// followed by `|` or a tab.
+	delim string
+	// fields is the list between the braces of a tabular array, as written,
+	// and tabular reports whether there is one.
+	fields  string
+	tabular bool
+}
+
+// parseArrayHeader parses s, the trimmed key part of the line at line, as an
+// array header, reporting false when s is a plain key. A length too large for
+// an int is a *SyntaxError.
+func parseArrayHeader(s string, line int) (arrayHeader, bool, error) {
+	var h arrayHeader
+
+	i := 0
+	if strings.HasPrefix(s, "\"") {
+		if i = quotedEnd(s); i < 0 {
+			return h, false, nil
+		}
+	} else if i = strings.IndexAny(s, "[\""); i < 0 || s[i] != '[' {
+		return h, false, nil
+	}
+	h.key = s[:i]
+	if i >= len(s) || s[i] != '[' {
+		return h, false, nil
+	}
+
+	rest := strings.TrimLeft(s[i+1:], " \t")
+	n := 0
+	for n < len(rest) && isDigit(rest[n]) {
+		n++
+	}
+	if n == 0 {
+		return h, false, nil
+	}
+	digits := rest[:n]
+	rest = rest[n:]
+
+	h.delim = ","
+	if rest != "" && (rest[0] == '|' || rest[0] == '\t') {
+		h.delim = rest[:1]
+		rest = rest[1:]
+	}
+	rest = strings.TrimLeft(rest, " \t")
+	if !strings.HasPrefix(rest, "]") {
+		return h, false, nil
+	}
+	rest = rest[1:]
+
+	switch {
+	case rest == "":
+	case len(rest) > 2 && rest[0] == '{' && rest[len(rest)-1] == '}':
+		h.fields, h.tabular = rest[1:len(rest)-1], true
+	default:
+		return h, false, nil
+	}
+
+	length, err := strconv.Atoi(digits)
+	if err != nil {
+		return h, false, &SyntaxError{msg: fmt.Sprintf("array length %s is out of range", digits), Line: line}
+	}
+	h.length = length
+	return h, true, nil
+}
+
+// quotedEnd returns the index just past the closing quote of the quoted
+// string s starts with, or -1 when it is not terminated.
+func quotedEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// splitKeyValue splits a `key: value` line on the first colon that is not
+// part of a quoted key.
+func splitKeyValue(line string) ([]string, error) {
+	var quoted, escaped bool
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == ':':
+			return []string{line[:i], line[i+1:]}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("goon: missing ':' after key in %q", strings.TrimSpace(line))
+}
+
+var (
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// mapKeyString returns the TOON key for the map key k. String keys are used
+// as is, keys implementing encoding.TextMarshaler are marshaled and integer
+// keys are formatted in base 10.
+func mapKeyString(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+
+	if k.Type().Implements(textMarshalerType) {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", nil
+		}
+		b, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return "", fmt.Errorf("goon: marshaling map key %v: %w", k, err)
+		}
+		return string(b), nil
+	}
+
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+
+	return "", fmt.Errorf("goon: unsupported map key type %s", k.Type())
+}
+
+// decodeMapKey converts the TOON key name into a value of the map key type
+// t, the inverse of mapKeyString.
+func decodeMapKey(t reflect.Type, name string) (reflect.Value, error) {
+	if t.Kind() == reflect.String {
+		return reflect.ValueOf(name).Convert(t), nil
+	}
+
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		key := reflect.New(t)
+		if err := key.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(name)); err != nil {
+			return reflect.Value{}, fmt.Errorf("goon: unmarshaling map key %q: %w", name, err)
+		}
+		return key.Elem(), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(name, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("goon: invalid map key %q for %s", name, t)
+		}
+		return reflect.ValueOf(n).Convert(t), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(name, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("goon: invalid map key %q for %s", name, t)
+		}
+		return reflect.ValueOf(n).Convert(t), nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("goon: unsupported map key type %s", t)
+}
+
+// setMapIndex stores val in the map m under the TOON key name, converting
+// both to the key and element types of the map.
+func (ds *decodeState) setMapIndex(m reflect.Value, name string, val reflect.Value) error {
+	key, err := decodeMapKey(m.Type().Key(), name)
+	if err != nil {
+		return err
+	}
+
+	elem := reflect.New(m.Type().Elem()).Elem()
+	if err := ds.assign(elem, val, ""); err != nil {
+		return err
+	}
+
+	m.SetMapIndex(key, elem)
+	return nil
+}
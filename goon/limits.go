@@ -0,0 +1,162 @@
+package goon
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Limits bounds the resources a Decoder spends on a single document. They
+// are meant for decoding untrusted input, such as the output of a language
+// model. A zero field means no limit.
+type Limits struct {
+	// MaxDocumentSize is the maximum size of the document in bytes.
+	MaxDocumentSize int
+	// MaxLineLength is the maximum length of a single line in bytes.
+	MaxLineLength int
+	// MaxArrayLength is the maximum length an array header `[N]` may declare.
+	MaxArrayLength int
+	// MaxDepth is the maximum nesting of objects, counting the top-level
+	// keys as depth 1.
+	MaxDepth int
+	// MaxKeys is the maximum number of keys in a single object, including
+	// the fields of a tabular array header.
+	MaxKeys int
+}
+
+// A LimitError is returned by a Decoder when its input exceeds one of the
+// configured Limits.
+type LimitError struct {
+	// Limit names the exceeded limit, such as "line length".
+	Limit string
+	// Max is the configured value of the limit.
+	Max int
+	// Line is the line at which the limit was exceeded, or 0 when the limit
+	// applies to the whole document.
+	Line int
+}
+
+func (e *LimitError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("goon: %s exceeds limit of %d", e.Limit, e.Max)
+	}
+	return fmt.Sprintf("goon: line %d: %s exceeds limit of %d", e.Line, e.Limit, e.Max)
+}
+
+// A SyntaxError describes malformed TOON input.
+type SyntaxError struct {
+	msg string
+	// Line is the line at which the error was detected.
+	Line int
+	// Column is the column at which the error was detected, counted in
+	// bytes from 1, or 0 when only the line is known.
+	Column int
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("goon: line %d: %s", e.Line, e.msg)
+}
+
+// SetLimits sets the resource limits applied to the documents read by the
+// decoder. Unmarshal applies no limits.
+func (dec *Decoder) SetLimits(limits Limits) {
+	dec.opts.limits = limits
+}
+
+// readDocument reads r until EOF, failing with a LimitError as soon as the
+// document grows beyond the maximum size.
+func (ds *decodeState) readDocument(r io.Reader) ([]byte, error) {
+	max := ds.limits.MaxDocumentSize
+	if max <= 0 {
+		return io.ReadAll(r)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, int64(max)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > max {
+		return nil, &LimitError{Limit: "document size", Max: max}
+	}
+	return data, nil
+}
+
+// checkArrayLength fails when an array header at line declares more
+// elements than allowed.
+func (ds *decodeState) checkArrayLength(length int, line int) error {
+	if max := ds.limits.MaxArrayLength; max > 0 && length > max {
+		return &LimitError{Limit: "array length", Max: max, Line: line}
+	}
+	return nil
+}
+
+// lineScanner is a bufio.Scanner over the lines of a document that keeps
+// track of the current line number.
+type lineScanner struct {
+	*bufio.Scanner
+	line    int
+	maxLine int
+	// held is set by Unscan for the current line to be scanned again.
+	held bool
+	// text stands for the current line when replaced is set by Replace.
+	text     string
+	replaced bool
+}
+
+// newLineScanner returns a lineScanner over data that accepts lines of up to
+// maxLine bytes, or of any length when maxLine is zero.
+func newLineScanner(data []byte, maxLine int) *lineScanner {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if maxLine > 0 {
+		scanner.Buffer(nil, maxLine+1)
+	} else {
+		scanner.Buffer(nil, len(data)+1)
+	}
+	return &lineScanner{Scanner: scanner, maxLine: maxLine}
+}
+
+func (s *lineScanner) Scan() bool {
+	if s.held {
+		s.held = false
+		return true
+	}
+	s.replaced = false
+	if !s.Scanner.Scan() {
+		return false
+	}
+	s.line++
+	return true
+}
+
+// Text returns the current line, or the text it was replaced with.
+func (s *lineScanner) Text() string {
+	if s.replaced {
+		return s.text
+	}
+	return s.Scanner.Text()
+}
+
+// Unscan makes the next call to Scan return the current line again, for a
+// line that ends a block and belongs to the caller.
+func (s *lineScanner) Unscan() {
+	s.held = true
+}
+
+// Replace makes the next call to Scan return text in place of the current
+// line, for a line that holds the first line of a block after a marker, such
+// as the first key of an object that is an item of a list.
+func (s *lineScanner) Replace(text string) {
+	s.held = true
+	s.text, s.replaced = text, true
+}
+
+// Err returns the first error encountered by the scanner, reporting lines
+// that are too long as a LimitError.
+func (s *lineScanner) Err() error {
+	err := s.Scanner.Err()
+	if err == bufio.ErrTooLong {
+		return &LimitError{Limit: "line length", Max: s.maxLine, Line: s.line + 1}
+	}
+	return err
+}
@@ -0,0 +1,171 @@
+package goon_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/roboogg133/goon/goon"
+)
+
+func TestLimits(t *testing.T) {
+
+	decode := func(data string, limits goon.Limits) error {
+		dec := goon.NewDecoder(strings.NewReader(data))
+		dec.SetLimits(limits)
+		v := make(map[string]any)
+		return dec.Decode(&v)
+	}
+
+	tests := []struct {
+		name   string
+		data   string
+		limits goon.Limits
+		limit  string
+		line   int
+	}{
+		{"document size", "a : 1\nb : 2\n", goon.Limits{MaxDocumentSize: 8}, "document size", 0},
+		{"line length", "a : 1\nb : " + strings.Repeat("x", 100) + "\n", goon.Limits{MaxLineLength: 64}, "line length", 2},
+		{"inline array length", "a[1000000000]: 1,2\n", goon.Limits{MaxArrayLength: 100}, "array length", 1},
+		{"list array length", "a : 1\nb[1000000000]:\n  - 1\n", goon.Limits{MaxArrayLength: 100}, "array length", 2},
+		{"tabular array length", "a[1000000000]{x,y}:\n  1,2\n", goon.Limits{MaxArrayLength: 100}, "array length", 1},
+		{"depth", "a :\n  b :\n    c : 1\n", goon.Limits{MaxDepth: 2}, "depth", 3},
+		{"keys", "a : 1\nb : 2\nc : 3\n", goon.Limits{MaxKeys: 2}, "keys per object", 3},
+		{"nested keys", "a :\n  x : 1\n  y : 2\nb :\n  x : 1\n  y : 2\n  z : 3\n", goon.Limits{MaxKeys: 2}, "keys per object", 7},
+		{"tabular keys", "a[1]{x,y,z}:\n  1,2,3\n", goon.Limits{MaxKeys: 2}, "keys per object", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := decode(tt.data, tt.limits)
+
+			var limitErr *goon.LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("got %v, want a LimitError", err)
+			}
+			if limitErr.Limit != tt.limit || limitErr.Line != tt.line {
+				t.Errorf("got %s at line %d, want %s at line %d", limitErr.Limit, limitErr.Line, tt.limit, tt.line)
+			}
+		})
+	}
+
+	t.Run("within limits", func(t *testing.T) {
+		limits := goon.Limits{MaxDocumentSize: 1024, MaxLineLength: 64, MaxArrayLength: 3, MaxDepth: 2, MaxKeys: 3}
+		if err := decode("a : 1\nb :\n  c[3]: 1,2,3\nd[2]{x,y}:\n  1,2\n  3,4\n", limits); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("truncated arrays", func(t *testing.T) {
+		for _, data := range []string{"a[5]:\n  - 1\n  - 2\n", "a[5]{x,y}:\n  1,2\n"} {
+			var syntaxErr *goon.SyntaxError
+			if err := decode(data, goon.Limits{}); !errors.As(err, &syntaxErr) {
+				t.Errorf("%q: got %v, want a SyntaxError", data, err)
+			}
+		}
+	})
+
+	t.Run("arrays ending early", func(t *testing.T) {
+		data := "x[2]:\n  - 1\nother : 5\ny[1]:\n  - 2\n"
+		dec := goon.NewDecoder(strings.NewReader(data))
+		dec.StrictIndentation()
+		var v struct {
+			X     []int `toon:"x"`
+			Other int   `toon:"other"`
+		}
+		var syntaxErr *goon.SyntaxError
+		if err := dec.Decode(&v); !errors.As(err, &syntaxErr) || syntaxErr.Line != 3 {
+			t.Errorf("Decode: got %v, want a SyntaxError at line 3", err)
+		}
+
+		r := goon.NewObjectReader([]byte(data))
+		var err error
+		for r.Next() && err == nil {
+			_, err = r.List()
+		}
+		if !errors.As(err, &syntaxErr) || syntaxErr.Line != 3 {
+			t.Errorf("ObjectReader.List: got %v, want a SyntaxError at line 3", err)
+		}
+	})
+
+	t.Run("tables ending early", func(t *testing.T) {
+		data := "x[2]{a}:\n  1\nother : 5\n"
+		var v map[string]any
+		var syntaxErr *goon.SyntaxError
+		if err := goon.Unmarshal([]byte(data), &v); !errors.As(err, &syntaxErr) || syntaxErr.Line != 3 {
+			t.Errorf("Unmarshal: got %v, want a SyntaxError at line 3", err)
+		}
+
+		r := goon.NewObjectReader([]byte(data))
+		var err error
+		for r.Next() && err == nil {
+			_, err = r.Table()
+		}
+		if !errors.As(err, &syntaxErr) || syntaxErr.Line != 3 {
+			t.Errorf("ObjectReader.Table: got %v, want a SyntaxError at line 3", err)
+		}
+	})
+
+	t.Run("inline arrays of another length", func(t *testing.T) {
+		for _, data := range []string{"a : 1\nnames[3]: a,b\n", "a : 1\nnames[1]: a,b\n"} {
+			var syntaxErr *goon.SyntaxError
+			if err := decode(data, goon.Limits{}); !errors.As(err, &syntaxErr) || syntaxErr.Line != 2 {
+				t.Errorf("%q: got %v, want a SyntaxError at line 2", data, err)
+			}
+
+			r := goon.NewObjectReader([]byte(data))
+			var err error
+			for r.Next() && err == nil {
+				if r.Key() == "names" {
+					_, err = r.List()
+				}
+			}
+			if !errors.As(err, &syntaxErr) || syntaxErr.Line != 2 {
+				t.Errorf("%q: ObjectReader.List: got %v, want a SyntaxError at line 2", data, err)
+			}
+
+			if _, err := goon.ToJSON([]byte(data)); !errors.As(err, &syntaxErr) || syntaxErr.Line != 2 {
+				t.Errorf("%q: ToJSON: got %v, want a SyntaxError at line 2", data, err)
+			}
+		}
+	})
+
+	t.Run("array lengths out of range", func(t *testing.T) {
+		for _, tt := range []struct {
+			data string
+			line int
+		}{
+			{"a : 1\nx[99999999999999999999]: 1\n", 2},
+			{"a : 1\nx[99999999999999999999]{a}:\n  1\n", 2},
+			{"a : 1\nx[1]:\n  - [99999999999999999999]: 1\n", 3},
+		} {
+			var syntaxErr *goon.SyntaxError
+			if err := decode(tt.data, goon.Limits{}); !errors.As(err, &syntaxErr) || syntaxErr.Line != tt.line {
+				t.Errorf("%q: got %v, want a SyntaxError at line %d", tt.data, err, tt.line)
+			}
+			if _, err := goon.ToJSON([]byte(tt.data)); !errors.As(err, &syntaxErr) || syntaxErr.Line != tt.line {
+				t.Errorf("%q: ToJSON: got %v, want a SyntaxError at line %d", tt.data, err, tt.line)
+			}
+		}
+
+		r := goon.NewObjectReader([]byte("a : 1\nx[99999999999999999999]: 1\n"))
+		for r.Next() {
+		}
+		var syntaxErr *goon.SyntaxError
+		if err := r.Err(); !errors.As(err, &syntaxErr) || syntaxErr.Line != 2 {
+			t.Errorf("ObjectReader: got %v, want a SyntaxError at line 2", err)
+		}
+	})
+
+	t.Run("long lines without limit", func(t *testing.T) {
+		long := strings.Repeat("x", 128*1024)
+		v := make(map[string]any)
+		if err := goon.Unmarshal([]byte("a : "+long+"\n"), &v); err != nil {
+			t.Fatal(err)
+		}
+		if v["a"] != long {
+			t.Errorf("got a value of %d bytes, want %d", len(v["a"].(string)), len(long))
+		}
+	})
+
+}
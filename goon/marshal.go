@@ -1,556 +1,902 @@
 package goon
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
+	"io"
+	"math"
 	"reflect"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"unsafe"
 )
 
+// Indentation is the indentation unit written by Marshal and by an Encoder
+// unless SetIndent is called.
 const Indentation = "  "
 
-func Marshal(v any) ([]byte, error) {
+// DefaultMaxDepth is the maximum nesting of objects and arrays an Encoder
+// accepts unless SetMaxDepth is called.
+const DefaultMaxDepth = 1000
+
+// An UnsupportedValueError is returned by Marshal when attempting to encode
+// a value that has no TOON representation, such as a cyclic data structure.
+type UnsupportedValueError struct {
+	Value reflect.Value
+	Str   string
+}
+
+func (e *UnsupportedValueError) Error() string {
+	return "goon: unsupported value: " + e.Str
+}
+
+// An Encoder writes TOON documents to an output stream.
+type Encoder struct {
+	w       io.Writer
+	opts    encOpts
+	summary Summary
+}
+
+// encOpts holds the options configured on an Encoder.
+type encOpts struct {
+	// timeFormat is the layout, or one of the TimeFormatUnix modes, used for
+	// time.Time values without a `format` tag. Empty means time.RFC3339Nano.
+	timeFormat string
+	// binaryEncoding is one of the Binary encodings, used for []byte and
+	// [N]byte values without a `format` tag. Empty means BinaryBase64.
+	binaryEncoding string
+	// maxDepth is the maximum nesting of objects and arrays. Zero means
+	// DefaultMaxDepth.
+	maxDepth int
+	// nilSliceAsNull and nilMapAsNull write nil slices and maps as `null`
+	// instead of as an empty array or object.
+	nilSliceAsNull bool
+	nilMapAsNull   bool
+	// indent is the number of spaces per nesting level. Zero means
+	// Indentation.
+	indent int
+	// budget bounds the size of the document.
+	budget Budget
+}
+
+// encodeState carries the options of a single Marshal or Encode call through
+// the encoder functions, along with the buffer they write to and the objects
+// and arrays that are currently being encoded.
+type encodeState struct {
+	encOpts
+
+	buf []byte
+	// unit is the indentation written once per nesting level and level is
+	// the nesting level of the lines being written.
+	unit  string
+	level int
+	// inline is set after a list item marker `- `, whose line the first key
+	// of an object continues instead of starting a new one.
+	inline bool
+
+	depth int
+	seen  map[visit]struct{}
+
+	// When the document has a budget, start is the offset of the document
+	// in buf and path the dotted path of the value being written. used is
+	// the number of tokens of buf[start:counted], and drops records the
+	// arrays truncated so far. Once an element doesn't fit, the budget is
+	// exhausted and no other element is written.
+	start     int
+	path      string
+	used      int
+	counted   int
+	exhausted bool
+	drops     []drop
+}
+
+// visit identifies an object or array being encoded, for cycle detection.
+type visit struct {
+	ptr unsafe.Pointer
+	typ reflect.Type
+	len int
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
 
-	rv := reflect.ValueOf(v)
-	rt := reflect.TypeOf(v)
+// SetTimeFormat sets how time.Time values without a `format` tag are encoded.
+// format is either a layout understood by time.Time.Format or one of
+// TimeFormatUnix, TimeFormatUnixMilli and TimeFormatUnixNano.
+func (enc *Encoder) SetTimeFormat(format string) {
+	enc.opts.timeFormat = format
+}
+
+// SetBinaryEncoding sets how []byte and [N]byte values without a `format` tag
+// are encoded: BinaryBase64, BinaryBase64URL or BinaryHex.
+func (enc *Encoder) SetBinaryEncoding(encoding string) {
+	enc.opts.binaryEncoding = encoding
+}
+
+// SetMaxDepth sets the maximum nesting of objects and arrays the encoder
+// accepts before failing with an UnsupportedValueError. A depth of zero or
+// less restores DefaultMaxDepth.
+func (enc *Encoder) SetMaxDepth(depth int) {
+	enc.opts.maxDepth = depth
+}
+
+// SetNilSliceAsNull sets whether nil slices are encoded as `null` instead of
+// as an empty array `[0]:`, the default. Nil []byte values are always `null`.
+func (enc *Encoder) SetNilSliceAsNull(on bool) {
+	enc.opts.nilSliceAsNull = on
+}
 
-	s, err := marshalSolve(rv, rt)
-	var data []byte
-	return fmt.Append(data, s), err
+// SetNilMapAsNull sets whether nil maps are encoded as `null` instead of as an
+// empty object, the default.
+func (enc *Encoder) SetNilMapAsNull(on bool) {
+	enc.opts.nilMapAsNull = on
 }
 
-func marshalSolve(rv reflect.Value, rt reflect.Type) (string, error) {
+// SetIndent sets the number of spaces written per nesting level, such as 2 or
+// 4. A width of zero or less restores the default of two spaces.
+func (enc *Encoder) SetIndent(width int) {
+	enc.opts.indent = width
+}
 
-	kind := rt.Kind()
-	if rt.Kind() == reflect.Interface {
-		rv = rv.Elem()
-		kind = rv.Kind()
+// Encode writes the TOON encoding of v to the stream, followed by a newline.
+// The buffer the document is written to is reused by later calls.
+func (enc *Encoder) Encode(v any) error {
+	es := newEncodeState(enc.opts)
+	defer es.release()
+
+	if err := es.marshal(v); err != nil {
+		return err
 	}
+	enc.summary = es.summary()
+	_, err := enc.w.Write(append(es.buf, '\n'))
+	return err
+}
 
-	switch kind {
-	case reflect.Struct, reflect.Map:
-		sttruc, err := marshalStruct(rv)
-		str, _ := strings.CutSuffix(string(sttruc), "\n")
-		return str, err
-	case reflect.String:
-		s := rv.String()
-		if strings.ContainsAny(s, "0123456789:,{}[]\"|\\-\t") {
-			s = fmt.Sprintf("\"%s\"", s)
-		} else if s == "" {
-			s = "\"\""
-		} else if s == "true" || s == "false" || s == "null" {
-			s = fmt.Sprintf("\"%s\"", s)
-		}
-		if strings.HasPrefix(s, " ") || strings.HasSuffix(s, " ") {
-			s = fmt.Sprintf("\"%s\"", s)
-		}
+// Marshal returns the TOON encoding of v.
+func Marshal(v any) ([]byte, error) {
+	es := newEncodeState(encOpts{})
+	defer es.release()
+
+	if err := es.marshal(v); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(es.buf), nil
+}
+
+// AppendMarshal appends the TOON encoding of v to dst, as returned by
+// Marshal, and returns the extended buffer. On error, dst is returned
+// unchanged.
+func AppendMarshal(dst []byte, v any) ([]byte, error) {
+	es := &encodeState{buf: dst}
+	if err := es.marshal(v); err != nil {
+		return dst, err
+	}
+	return es.buf, nil
+}
 
-		return s, nil
+// maxPooledBuffer is the capacity above which the buffer of an encodeState
+// is not kept for later calls, so that a single large document doesn't keep
+// its memory alive.
+const maxPooledBuffer = 64 << 10
 
-	case reflect.Int:
-		return fmt.Sprint(rv.Int()), nil
-	case reflect.Float64, reflect.Float32:
-		return fmt.Sprint(rv.Float()), nil
-	case reflect.Array, reflect.Slice:
-		var builder strings.Builder
-		builder.WriteString(fmt.Sprintf("[%d]", rv.Len()))
-		s, error := arrayMarshal(rv)
-		builder.WriteString(s)
-		str, _ := strings.CutSuffix(builder.String(), "\n")
-		return str, error
+var encodeStatePool sync.Pool
 
-	default:
-		return "", fmt.Errorf("goon: invalid type for marshal: %s", rt.Kind())
+// newEncodeState returns an encodeState with the options opts, reusing the
+// buffer of a previous call when there is one.
+func newEncodeState(opts encOpts) *encodeState {
+	if es, ok := encodeStatePool.Get().(*encodeState); ok {
+		es.encOpts = opts
+		return es
 	}
+	return &encodeState{encOpts: opts}
 }
 
-type entry struct {
-	Name      string
-	Value     reflect.Value
-	OmitEmpty bool
+// release puts es back into the pool once its buffer is no longer used.
+func (es *encodeState) release() {
+	if cap(es.buf) > maxPooledBuffer {
+		return
+	}
+	*es = encodeState{buf: es.buf[:0], seen: es.seen}
+	clear(es.seen)
+	encodeStatePool.Put(es)
 }
 
-func normalize(v reflect.Value) ([]entry, error) {
-	t := v.Type()
+// marshal appends the encoding of v to es.buf, without a trailing newline.
+func (es *encodeState) marshal(v any) error {
+	start := len(es.buf)
+	es.unit = es.indentation()
+	if es.budget.Tokens > 0 && es.budget.Tokenizer == nil {
+		return errNoTokenizer
+	}
+	es.start, es.counted = start, start
+	if err := es.encode(reflect.ValueOf(v), ""); err != nil {
+		return err
+	}
+	if len(es.buf) > start && es.buf[len(es.buf)-1] == '\n' {
+		es.buf = es.buf[:len(es.buf)-1]
+	}
+	return nil
+}
 
-	switch v.Kind() {
+// encode appends v as the root of a document: a primitive value as is, an
+// object as its fields and an array as its header followed by its elements.
+func (es *encodeState) encode(v reflect.Value, format string) error {
+	v = indirectValue(v)
+	if es.isNull(v) {
+		es.buf = append(es.buf, "null"...)
+		return nil
+	}
+	return encoderOf(v.Type()).encode(es, v, format)
+}
 
-	case reflect.Struct:
-		var out []entry
-		for i := 0; i < t.NumField(); i++ {
-			f := t.Field(i)
-			name := f.Tag.Get("toon")
-			if name == "" {
-				continue
-			}
-			var omit bool
-			if f.Tag.Get("omitempty") != "" {
-				omit = true
-			}
+// shape is the form a TOON value takes in a document, which decides how it
+// follows a key or a list item marker.
+type shape int
+
+const (
+	// primitiveShape values, such as strings, numbers and times, are written
+	// on the line of their key.
+	primitiveShape shape = iota
+	// objectShape values, structs and maps, are written as one line per key.
+	objectShape
+	// arrayShape values write their own `[N]` header after their key.
+	arrayShape
+)
 
-			out = append(out, entry{
-				Name:      name,
-				Value:     v.Field(i),
-				OmitEmpty: omit,
-			})
+// A typeEncoder writes the values of a single type. encode appends v, which
+// is neither a pointer nor an interface, to es.buf: primitives without a
+// trailing newline, objects as complete lines at es.level and arrays as their
+// header followed by their elements on lines at es.level+1.
+type typeEncoder struct {
+	shape  shape
+	encode func(es *encodeState, v reflect.Value, format string) error
+}
 
-		}
-		return out, nil
+// encoderCache maps a reflect.Type to its *typeEncoder.
+var encoderCache sync.Map
 
-	case reflect.Map:
-		var out []entry
-		for _, key := range v.MapKeys() {
-			k := key.Interface()
-			name := fmt.Sprint(k)
-			out = append(out, entry{
-				Name:  name,
-				Value: v.MapIndex(key),
-			})
-		}
-		return out, nil
+// encoderOf returns the cached encoder of the type t, building it on first
+// use. Encoders look up the encoders of nested values when encoding, so that
+// recursive types need no special care.
+func encoderOf(t reflect.Type) *typeEncoder {
+	if enc, ok := encoderCache.Load(t); ok {
+		return enc.(*typeEncoder)
+	}
+	enc, _ := encoderCache.LoadOrStore(t, newTypeEncoder(t))
+	return enc.(*typeEncoder)
+}
 
-	default:
-		return nil, fmt.Errorf("goon: unsupported kind %s", v.Kind())
+func newTypeEncoder(t reflect.Type) *typeEncoder {
+	if enc := jsonEncoder(t); enc != nil {
+		return enc
+	}
+	if t.Implements(objectMarshalerType) || reflect.PointerTo(t).Implements(objectMarshalerType) {
+		return newObjectMarshalerEncoder(t, newKindEncoder(t))
+	}
+	if t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType) {
+		return newMarshalerEncoder(t, newKindEncoder(t))
 	}
+	return newKindEncoder(t)
 }
 
-// marshalStruct marshals a struct or map value into the goon textual representation.
-//
-// It accepts a reflect.Value that must represent a struct or map (as produced by normalize)
-// and returns the marshaled bytes or an error. Nil pointer fields are emitted as `null` unless
-// the field has an `omitempty` tag, in which case they are omitted. String values are quoted
-// when they contain special characters, are empty, equal `"true"`, `"false"`, or `"null"`,
-// or begin/end with a space. Nested structs and maps are emitted as indented blocks (two-space
-// indentation per nesting level). Array and slice fields are formatted using the array marshal
-// conventions (including a `Name[length]` header). An error is returned for unsupported kinds
-// or when normalization fails.
-func marshalStruct(v reflect.Value) ([]byte, error) {
-	entries, err := normalize(v)
-	if err != nil {
-		return nil, err
+// newKindEncoder returns the encoder of the type t based on its kind.
+func newKindEncoder(t reflect.Type) *typeEncoder {
+	if isScalarType(t) {
+		return &typeEncoder{primitiveShape, encodeScalar}
 	}
 
-	var final strings.Builder
+	switch t.Kind() {
+	case reflect.String:
+		return &typeEncoder{primitiveShape, encodeString}
+	case reflect.Bool:
+		return &typeEncoder{primitiveShape, encodeBool}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &typeEncoder{primitiveShape, encodeInt}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &typeEncoder{primitiveShape, encodeUint}
+	case reflect.Float32, reflect.Float64:
+		return &typeEncoder{primitiveShape, encodeFloat}
+	case reflect.Struct:
+		return &typeEncoder{objectShape, encodeStruct}
+	case reflect.Map:
+		return &typeEncoder{objectShape, encodeMap}
+	case reflect.Array, reflect.Slice:
+		return &typeEncoder{arrayShape, newArrayEncoder(t)}
+	default:
+		return &typeEncoder{primitiveShape, encodeUnsupported}
+	}
+}
 
-	for _, e := range entries {
+func encodeScalar(es *encodeState, v reflect.Value, format string) error {
+	s, _ := es.marshalScalar(v, format)
+	es.buf = append(es.buf, s...)
+	return nil
+}
 
-		value := e.Value
-		valKind := value.Kind()
+func encodeString(es *encodeState, v reflect.Value, _ string) error {
+	es.buf = append(es.buf, formatString(v.String())...)
+	return nil
+}
 
-		if valKind == reflect.Interface {
-			value = value.Elem()
-			valKind = value.Kind()
-		}
+func encodeBool(es *encodeState, v reflect.Value, _ string) error {
+	es.buf = strconv.AppendBool(es.buf, v.Bool())
+	return nil
+}
 
-		if valKind == reflect.Pointer {
-			if value.IsNil() && !e.OmitEmpty {
-				final.WriteString(fmt.Sprintf("%s : %s\n", e.Name, "null"))
-			}
-			value = value.Elem()
-			valKind = value.Kind()
-		}
+func encodeInt(es *encodeState, v reflect.Value, _ string) error {
+	es.buf = strconv.AppendInt(es.buf, v.Int(), 10)
+	return nil
+}
 
-		var a string
-
-		switch valKind {
-		case reflect.String:
-			s := value.String()
-			if strings.ContainsAny(s, "0123456789:,{}[]\"|\\-\t") {
-				s = fmt.Sprintf("\"%s\"", s)
-			} else if s == "" {
-				s = "\"\""
-			} else if s == "true" || s == "false" || s == "null" {
-				s = fmt.Sprintf("\"%s\"", s)
-			}
-			if strings.HasPrefix(s, " ") || strings.HasSuffix(s, " ") {
-				s = fmt.Sprintf("\"%s\"", s)
-			}
+func encodeUint(es *encodeState, v reflect.Value, _ string) error {
+	es.buf = strconv.AppendUint(es.buf, v.Uint(), 10)
+	return nil
+}
 
-			a = fmt.Sprintf("%s : %s\n", e.Name, s)
-		case reflect.Int:
-			a = fmt.Sprintf("%s : %s\n", e.Name, fmt.Sprint(value.Int()))
-		case reflect.Float32, reflect.Float64:
-			a = fmt.Sprintf("%s : %s\n", e.Name, fmt.Sprint(value.Float()))
-		case reflect.Bool:
-			a = fmt.Sprintf("%s : %s\n", e.Name, fmt.Sprint(value.Bool()))
-		case reflect.Struct, reflect.Map:
-			content, err := marshalStruct(value)
-			if err != nil {
-				return nil, err
-			}
+// encodeFloat writes v with the fewest digits that read back as the same
+// value of its size. NaN and infinities have no TOON representation.
+func encodeFloat(es *encodeState, v reflect.Value, _ string) error {
+	f := v.Float()
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return &UnsupportedValueError{v, strconv.FormatFloat(f, 'g', -1, 64)}
+	}
+	es.buf = strconv.AppendFloat(es.buf, f, 'g', -1, v.Type().Bits())
+	return nil
+}
 
-			str := string(content)
+func encodeUnsupported(_ *encodeState, v reflect.Value, _ string) error {
+	return &UnsupportedTypeError{v.Type()}
+}
 
-			var builder strings.Builder
-			scanner := bufio.NewScanner(strings.NewReader(str))
-			for scanner.Scan() {
-				builder.WriteString(Indentation)
-				builder.WriteString(scanner.Text())
-				builder.WriteByte('\n')
-			}
+// An UnsupportedTypeError is returned by Marshal when attempting to encode
+// a value of a type that has no TOON representation, such as a channel or a
+// function.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
 
-			a = fmt.Sprintf("%s :\n%s", e.Name, builder.String())
+func (e *UnsupportedTypeError) Error() string {
+	return "goon: unsupported type: " + e.Type.String()
+}
 
-		case reflect.Array, reflect.Slice:
-			var builder strings.Builder
-			builder.WriteString(fmt.Sprintf("%s[%d]", e.Name, value.Len()))
+// newline starts a new line at the current level, unless the line of a list
+// item marker is to be continued.
+func (es *encodeState) newline() {
+	if es.inline {
+		es.inline = false
+		return
+	}
+	for range es.level {
+		es.buf = append(es.buf, es.unit...)
+	}
+}
 
-			s, err := arrayMarshal(value)
-			if err != nil {
-				return nil, err
-			}
-			builder.WriteString(s)
+// encodeStruct writes the fields of a struct that have a `toon` tag, in the
+// order they are declared. Nil fields tagged `omitempty` are skipped.
+func encodeStruct(es *encodeState, v reflect.Value, _ string) error {
+	if err := es.enter(v); err != nil {
+		return err
+	}
+	defer es.leave(v)
 
-			final.WriteString(builder.String())
+	for _, f := range typeFields(v.Type()) {
+		fv := indirectValue(v.Field(f.index))
+		if f.omitEmpty && isNil(fv) {
+			continue
+		}
+		if err := es.encodeKey(f.key, fv, f.format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		default:
-			return nil, fmt.Errorf("goon: unknown type kind %s", valKind)
+// encodeMap writes the entries of a map sorted by key.
+func encodeMap(es *encodeState, v reflect.Value, _ string) error {
+	if err := es.enter(v); err != nil {
+		return err
+	}
+	defer es.leave(v)
+
+	entries, err := mapEntries(v)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := es.encodeKey(e.Name, indirectValue(e.Value), ""); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		final.WriteString(a)
+// encodeKey writes the key name of an object along with its value v, which
+// is already indirected: `name : value` for primitives, `name :` followed by
+// the nested fields for objects and `name[N]...` for arrays.
+func (es *encodeState) encodeKey(name string, v reflect.Value, format string) error {
+	es.newline()
+	es.buf = append(es.buf, name...)
+	if es.budgeted() {
+		parent := es.path
+		es.path = joinPath(parent, name)
+		defer func() { es.path = parent }()
 	}
 
-	return []byte(final.String()), nil
+	if es.isNull(v) {
+		es.buf = append(es.buf, " : null\n"...)
+		return nil
+	}
+
+	enc := encoderOf(v.Type())
+	switch enc.shape {
+	case objectShape:
+		es.buf = append(es.buf, " :\n"...)
+		es.level++
+		err := enc.encode(es, v, format)
+		es.level--
+		return err
+	case arrayShape:
+		return enc.encode(es, v, format)
+	}
+
+	es.buf = append(es.buf, " : "...)
+	if err := enc.encode(es, v, format); err != nil {
+		return err
+	}
+	es.buf = append(es.buf, '\n')
+	return nil
+}
+
+type entry struct {
+	Name  string
+	Value reflect.Value
+	// Format is the `format` tag of the field, used for time.Time values.
+	Format string
 }
 
-// arrayMarshal formats an array or slice value into the goon sequence representation.
-// It emits a header for the first element (": ") and separates elements with commas; empty slices are represented as ":\n".
-// Pointer elements are dereferenced with nil pointers rendered as "null". For string elements, values containing digits or
-// punctuation, the empty string, the literals "true", "false", or "null", or values with leading/trailing spaces are quoted.
-// If any element is a complex kind (array, slice, interface, map, or struct) control is delegated to arrayMixMarshal.
-// Returns the formatted sequence as a string with any trailing newline removed, or an error for unsupported element kinds.
-func arrayMarshal(value reflect.Value) (string, error) {
-	var builder strings.Builder
+// mapEntries returns the entries of the map v sorted by key, with the keys
+// formatted as TOON keys.
+func mapEntries(v reflect.Value) ([]entry, error) {
+	out := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		name, err := mapKeyString(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, entry{Name: name, Value: iter.Value()})
+	}
+	slices.SortFunc(out, func(a, b entry) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	for i := range out {
+		out[i].Name = formatKey(out[i].Name)
+	}
+	return out, nil
+}
+
+// objectEntries returns the keys of the struct or map v along with their
+// values, in the order they are encoded.
+func objectEntries(v reflect.Value) ([]entry, error) {
+	if v.Kind() == reflect.Map {
+		return mapEntries(v)
+	}
+	if v.Type() == jsonObjectType {
+		return v.Interface().(jsonObject).entries(), nil
+	}
+
+	fields := typeFields(v.Type())
+	out := make([]entry, len(fields))
+	for i, f := range fields {
+		out[i] = entry{Name: f.key, Value: v.Field(f.index), Format: f.format}
+	}
+	return out, nil
+}
 
-	if value.Len() == 0 {
-		builder.WriteString(":\n")
+// newArrayEncoder returns the encoder of the array or slice type t. Arrays
+// of uniform objects are written as tabular arrays, arrays of primitives on
+// a single line and any other array as a list, one element per line.
+func newArrayEncoder(t reflect.Type) func(es *encodeState, v reflect.Value, format string) error {
+	elem := t.Elem()
+	for elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
 	}
+	list := !isScalarType(elem) && !isPrimitiveKind(elem.Kind())
+	table := tableOf(t.Elem())
 
-	for i := 0; i < value.Len(); i++ {
-		elem := value.Index(i)
-		valKind := elem.Kind()
+	return func(es *encodeState, v reflect.Value, _ string) error {
+		if err := es.enter(v); err != nil {
+			return err
+		}
+		defer es.leave(v)
+
+		es.buf = append(es.buf, '[')
+		count := len(es.buf)
+		es.buf = strconv.AppendInt(es.buf, int64(v.Len()), 10)
+		es.buf = append(es.buf, ']')
+
+		if v.Len() == 0 {
+			es.buf = append(es.buf, ":\n"...)
+			return nil
+		}
 
-		if valKind == reflect.Pointer {
-			if value.IsNil() {
-				if value.Len()-1 == i {
-					builder.WriteString("null")
-					builder.WriteByte('\n')
-				} else {
-					builder.WriteString("null" + ",")
+		if ok, err := table(es, v, count); ok || err != nil {
+			return err
+		}
+
+		if list {
+			es.buf = append(es.buf, ":\n"...)
+			for i := 0; i < v.Len(); i++ {
+				m, ok := es.mark(count, i, v.Len())
+				if !ok {
+					break
+				}
+				if err := es.encodeItem(v.Index(i)); err != nil {
+					return err
+				}
+				if !es.fits(m) {
+					break
 				}
 			}
-			elem = elem.Elem()
-			valKind = elem.Kind()
+			return nil
 		}
 
-		if valKind == reflect.Array || valKind == reflect.Slice || valKind == reflect.Interface || valKind == reflect.Map || valKind == reflect.Struct {
-			return arrayMixMarshal(value)
+		return es.encodeInline(v)
+	}
+}
+
+// encodeInline writes the elements of the array v, which are primitives, on
+// the line of its header.
+func (es *encodeState) encodeInline(v reflect.Value) error {
+	es.buf = append(es.buf, ": "...)
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			es.buf = append(es.buf, ',')
 		}
-		switch valKind {
-		case reflect.String:
-			if i == 0 {
-				builder.WriteString(": ")
-			}
-			s := elem.String()
-			if strings.ContainsAny(s, "0123456789:,{}[]\"|\\-\t") {
-				s = fmt.Sprintf("\"%s\"", s)
-			} else if s == "" {
-				s = "\"\""
-			} else if s == "true" || s == "false" || s == "null" {
-				s = fmt.Sprintf("\"%s\"", s)
-			}
-			if strings.HasPrefix(s, " ") || strings.HasSuffix(s, " ") {
-				s = fmt.Sprintf("\"%s\"", s)
-			}
-			if value.Len()-1 == i {
-				builder.WriteString(s)
-				builder.WriteByte('\n')
-			} else {
-				builder.WriteString(s + ",")
-			}
+		if err := es.encodeCell(v.Index(i), ""); err != nil {
+			return err
+		}
+	}
+	es.buf = append(es.buf, '\n')
+	return nil
+}
 
-		case reflect.Int:
-			if i == 0 {
-				builder.WriteString(": ")
-			}
-			if value.Len()-1 == i {
-				builder.WriteString(fmt.Sprint(elem.Int()))
-				builder.WriteByte('\n')
-			} else {
-				builder.WriteString(fmt.Sprint(elem.Int()) + ",")
-			}
+// encodeItem writes v as an element of a list, on its own line after a
+// `- ` marker. The first key of an object continues the line of the marker
+// and the others are nested one level below it.
+func (es *encodeState) encodeItem(v reflect.Value) error {
+	v = indirectValue(v)
+
+	es.level++
+	defer func() { es.level-- }()
+	es.newline()
+	es.buf = append(es.buf, "- "...)
+	if es.budgeted() {
+		parent := es.path
+		es.path += "[]"
+		defer func() { es.path = parent }()
+	}
 
-		case reflect.Float32, reflect.Float64:
-			if i == 0 {
-				builder.WriteString(": ")
-			}
-			if value.Len()-1 == i {
-				builder.WriteString(fmt.Sprint(elem.Float()))
-				builder.WriteByte('\n')
-			} else {
-				builder.WriteString(fmt.Sprint(elem.Float()) + ",")
-			}
-		case reflect.Bool:
-			if i == 0 {
-				builder.WriteString(": ")
-			}
-			if value.Len()-1 == i {
-				builder.WriteString(fmt.Sprint(elem.Bool()))
-				builder.WriteByte('\n')
-			} else {
-				builder.WriteString(fmt.Sprint(elem.Bool()) + ",")
-			}
+	if es.isNull(v) {
+		es.buf = append(es.buf, "null\n"...)
+		return nil
+	}
 
-		default:
-			return "", fmt.Errorf("goon: unknown type kind %s", valKind)
+	enc := encoderOf(v.Type())
+	switch enc.shape {
+	case objectShape:
+		es.level++
+		es.inline = true
+		err := enc.encode(es, v, "")
+		es.level--
+		if es.inline {
+			// The object is empty, so the line of the marker is left as is.
+			es.inline = false
+			es.buf = append(es.buf[:len(es.buf)-1], '\n')
 		}
+		return err
+	case arrayShape:
+		return enc.encode(es, v, "")
 	}
-	return builder.String(), nil
 
+	if err := enc.encode(es, v, ""); err != nil {
+		return err
+	}
+	es.buf = append(es.buf, '\n')
+	return nil
 }
 
-func arrayMixMarshal(value reflect.Value) (string, error) {
-	var builder strings.Builder
-
-	if value.Len() == 0 {
-		builder.WriteString(":\n")
+// encodeCell writes v as an element of an inline array or a cell of a
+// tabular array. v must be null or a primitive.
+func (es *encodeState) encodeCell(v reflect.Value, format string) error {
+	v = indirectValue(v)
+	if es.isNull(v) {
+		es.buf = append(es.buf, "null"...)
+		return nil
 	}
+	return encoderOf(v.Type()).encode(es, v, format)
+}
 
-	for i := 0; i < value.Len(); i++ {
-		elem := value.Index(i)
-		valKind := elem.Kind()
+// tableOf returns the function writing the tabular form of an array whose
+// elements are of type elem, which reports false without writing anything
+// when the elements are not uniform objects. The length of the array is
+// written at es.buf[count:].
+//
+// A tabular array starts with the keys of its elements enclosed in braces,
+// e.g. "{a,b,c}:", followed by one line per element with the values separated
+// by commas. It applies to arrays of uniform objects only: every element must
+// be a struct or map (interfaces and pointers wrapping those are accepted)
+// with the same keys, in the same order, and only primitive values. Arrays of
+// structs whose fields are all primitives are known to be uniform from their
+// type alone.
+func tableOf(elem reflect.Type) func(es *encodeState, v reflect.Value, count int) (bool, error) {
+	t := elem
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
 
-		if valKind == reflect.Pointer {
-			if value.IsNil() {
-				builder.WriteString("  - null\n")
+	switch {
+	case t.Kind() == reflect.Struct && !isScalarType(t):
+		fields := typeFields(t)
+		if len(fields) > 0 && !slices.ContainsFunc(fields, func(f field) bool { return !f.primitive }) {
+			return func(es *encodeState, v reflect.Value, count int) (bool, error) {
+				return es.encodeStructTable(v, fields, count)
 			}
-			elem = elem.Elem()
-			valKind = elem.Kind()
 		}
+		fallthrough
+	case t.Kind() == reflect.Map || t.Kind() == reflect.Interface:
+		return (*encodeState).encodeTable
+	}
 
-		if valKind == reflect.Interface {
-			elem = elem.Elem()
-			valKind = elem.Kind()
+	return func(*encodeState, reflect.Value, int) (bool, error) {
+		return false, nil
+	}
+}
+
+// encodeStructTable writes the array v, whose elements are structs with the
+// primitive fields fields, as a tabular array. Only nil elements prevent it.
+func (es *encodeState) encodeStructTable(v reflect.Value, fields []field, count int) (bool, error) {
+	for i := 0; i < v.Len(); i++ {
+		if isNil(indirectValue(v.Index(i))) {
+			return false, nil
 		}
+	}
 
-		if valKind == reflect.Struct || valKind == reflect.Map {
-			str, err := doTheCSVThingORNothing(value)
-			if err == nil {
-				return str, nil
-			}
+	es.buf = append(es.buf, '{')
+	for i, f := range fields {
+		if i > 0 {
+			es.buf = append(es.buf, ',')
 		}
-		switch valKind {
-		case reflect.String:
-			s := elem.String()
-			if strings.ContainsAny(s, "0123456789:,{}[]\"|\\-\t") {
-				s = fmt.Sprintf("\"%s\"", s)
-			} else if s == "" {
-				s = "\"\""
-			} else if s == "true" || s == "false" || s == "null" {
-				s = fmt.Sprintf("\"%s\"", s)
-			}
-			if strings.HasPrefix(s, " ") || strings.HasSuffix(s, " ") {
-				s = fmt.Sprintf("\"%s\"", s)
+		es.buf = append(es.buf, f.key...)
+	}
+	es.buf = append(es.buf, "}:\n"...)
+
+	es.level++
+	defer func() { es.level-- }()
+	for i := 0; i < v.Len(); i++ {
+		m, ok := es.mark(count, i, v.Len())
+		if !ok {
+			break
+		}
+		row := indirectValue(v.Index(i))
+		es.newline()
+		for j, f := range fields {
+			if j > 0 {
+				es.buf = append(es.buf, ',')
 			}
-
-			if i == 0 {
-				builder.WriteRune(':')
-				builder.WriteByte('\n')
+			if err := es.encodeCell(row.Field(f.index), f.format); err != nil {
+				return false, err
 			}
+		}
+		es.buf = append(es.buf, '\n')
+		if !es.fits(m) {
+			break
+		}
+	}
+	return true, nil
+}
 
-			builder.WriteString(Indentation + "- " + s + "\n")
-
-		case reflect.Int:
-			if i == 0 {
-				builder.WriteRune(':')
-				builder.WriteByte('\n')
-			}
-			builder.WriteString(Indentation + "- " + fmt.Sprint(elem.Int()) + "\n")
+// encodeTable writes the array v as a tabular array when its elements turn
+// out to be uniform objects once inspected one by one.
+func (es *encodeState) encodeTable(v reflect.Value, count int) (bool, error) {
+	var keys []string
+	rows := make([][]entry, v.Len())
 
-		case reflect.Float32, reflect.Float64:
-			if i == 0 {
-				builder.WriteRune(':')
-				builder.WriteByte('\n')
-			}
-			builder.WriteString(Indentation + "- " + fmt.Sprint(elem.Float()) + "\n")
+	for i := 0; i < v.Len(); i++ {
+		elem := indirectValue(v.Index(i))
+		elemKind := elem.Kind()
 
-		case reflect.Bool:
-			if i == 0 {
-				builder.WriteRune(':')
-				builder.WriteByte('\n')
-			}
-			builder.WriteString(Indentation + "- " + fmt.Sprint(elem.Bool()) + "\n")
+		if elemKind != reflect.Map && elemKind != reflect.Struct || isNil(elem) || isScalarType(elem.Type()) {
+			return false, nil
+		}
+		entries, err := objectEntries(elem)
+		if err != nil {
+			return false, err
+		}
+		if len(entries) == 0 {
+			return false, nil
+		}
 
-		case reflect.Struct, reflect.Map:
+		for j, e := range entries {
 			if i == 0 {
-				entrys, err := normalize(elem)
-				if err != nil {
-					return "", err
-				}
-				if value.Index(i).Type().Kind() != reflect.Interface {
-					builder.WriteRune('{')
-					total := len(entrys) - 1
-					for i, e := range entrys {
-						builder.WriteString(e.Name)
-						if i == total {
-							continue
-						}
-						builder.WriteString(",")
-					}
-					builder.WriteRune('}')
-					builder.WriteRune(':')
-					builder.WriteByte('\n')
-				} else {
-					builder.WriteRune(':')
-					builder.WriteByte('\n')
-				}
+				keys = append(keys, e.Name)
+			} else if len(entries) != len(keys) || keys[j] != e.Name {
+				return false, nil
 			}
-
-			entrys, err := normalize(elem)
-			if err != nil {
-				return "", err
-			}
-			if value.Index(i).Type().Kind() != reflect.Interface {
-				total := len(entrys) - 1
-				builder.WriteString(Indentation)
-				for i, e := range entrys {
-
-					s, err := marshalSolve(e.Value, e.Value.Type())
-					if err != nil {
-						return "", err
-					}
-					builder.WriteString(s)
-					if i != total {
-						builder.WriteString(",")
-					}
-				}
-				builder.WriteByte('\n')
-			} else {
-				content, err := marshalStruct(elem)
-				if err != nil {
-					return "", err
-				}
-
-				str := string(content)
-
-				var indented strings.Builder
-				scanner := bufio.NewScanner(strings.NewReader(str))
-				lock := true
-				for scanner.Scan() {
-					if lock {
-						lock = false
-					} else {
-						indented.WriteString(Indentation + "  ")
-					}
-					indented.WriteString(scanner.Text())
-					indented.WriteByte('\n')
-				}
-
-				builder.WriteString("  - ")
-				builder.WriteString(indented.String())
+			if !es.isPrimitive(e.Value) {
+				return false, nil
 			}
+		}
+		rows[i] = entries
+	}
 
-		case reflect.Array, reflect.Slice:
-			var build strings.Builder
+	es.buf = append(es.buf, '{')
+	es.buf = append(es.buf, strings.Join(keys, ",")...)
+	es.buf = append(es.buf, "}:\n"...)
 
-			if i == 0 {
-				builder.WriteRune(':')
-				builder.WriteByte('\n')
+	es.level++
+	defer func() { es.level-- }()
+	for i, entries := range rows {
+		m, ok := es.mark(count, i, len(rows))
+		if !ok {
+			break
+		}
+		es.newline()
+		for j, e := range entries {
+			if j > 0 {
+				es.buf = append(es.buf, ',')
 			}
-			build.WriteString(fmt.Sprintf("  - [%d]", elem.Len()))
-
-			s, err := arrayMarshal(elem)
-			if err != nil {
-				return "", err
+			if err := es.encodeCell(e.Value, e.Format); err != nil {
+				return false, err
 			}
-			build.WriteString(s)
-			builder.WriteString(build.String())
-
-		default:
-			return "", fmt.Errorf("goon: unknown type kind %s", valKind)
+		}
+		es.buf = append(es.buf, '\n')
+		if !es.fits(m) {
+			break
 		}
 	}
+	return true, nil
+}
 
-	return builder.String(), nil
+// isPrimitive reports whether v is encoded as a single TOON value that may
+// appear in a row of a tabular array: null, a string, a number, a boolean or
+// one of the values handled by marshalScalar.
+func (es *encodeState) isPrimitive(v reflect.Value) bool {
+	v = indirectValue(v)
+	if es.isNull(v) {
+		return true
+	}
+	return isScalarType(v.Type()) || isPrimitiveKind(v.Kind())
 }
 
-// doTheCSVThingORNothing converts a slice value into a compact CSV-like block.
-// The output begins with a header of all encountered field names enclosed in
-// braces (e.g. "{a,b,c}:") followed by one indented row per slice element with
-// field values separated by commas; missing fields are rendered as `null`.
-// The function expects rv to be a slice whose elements are structs or maps
-// (interfaces wrapping those are accepted). It returns the assembled string
-// (without a trailing newline) or an error if an element is not a struct/map or
-// if normalization/marshalling of any field fails.
-func doTheCSVThingORNothing(rv reflect.Value) (string, error) {
-	var builder strings.Builder
+// isPrimitiveKind reports whether values of kind k are strings, numbers or
+// booleans.
+func isPrimitiveKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
 
-	var allnames []string
+// indirectValue follows interfaces and pointers down to the value they refer
+// to, stopping at the first nil one.
+func indirectValue(v reflect.Value) reflect.Value {
+	for (v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
 
-	var allEntrys []map[string]reflect.Value
+// isNil reports whether v is invalid or a nil pointer, interface, slice or map.
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+		return v.IsNil()
+	}
+	return false
+}
 
-	for i := 0; i < rv.Len(); i++ {
-		elem := rv.Index(i)
-		elemKind := elem.Kind()
+// isNull reports whether v is encoded as `null`. Nil pointers and interfaces
+// always are, while nil slices and maps are only when the encoder is set to
+// do so.
+func (es *encodeState) isNull(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice:
+		return v.IsNil() && es.nilSliceAsNull
+	case reflect.Map:
+		return v.IsNil() && es.nilMapAsNull
+	}
+	return isNil(v)
+}
 
-		if elemKind == reflect.Interface {
-			elem = elem.Elem()
-			elemKind = elem.Kind()
-		}
+// enter records that v, a struct, map, array or slice, is being encoded. It
+// fails when v is already being encoded further up, which means the value is
+// cyclic, or when the nesting exceeds the maximum depth. Every successful
+// call must be paired with a call to leave.
+func (es *encodeState) enter(v reflect.Value) error {
+	maxDepth := es.maxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	if es.depth >= maxDepth {
+		return &UnsupportedValueError{v, fmt.Sprintf("exceeded max depth of %d", maxDepth)}
+	}
 
-		if elemKind != reflect.Map && elemKind != reflect.Struct {
-			return "", fmt.Errorf("goon: can't do the csv thing ")
+	if key, ok := visitOf(v); ok {
+		if _, cycle := es.seen[key]; cycle {
+			return &UnsupportedValueError{v, fmt.Sprintf("encountered a cycle via %s", v.Type())}
 		}
-		entrys, err := normalize(elem)
-		if err != nil {
-			return "", err
+		if es.seen == nil {
+			es.seen = make(map[visit]struct{})
 		}
+		es.seen[key] = struct{}{}
+	}
 
-		entryM := make(map[string]reflect.Value)
-		for _, e := range entrys {
-			if !slices.Contains(allnames, e.Name) {
-				allnames = append(allnames, e.Name)
-			}
-			entryM[e.Name] = e.Value
-		}
-		allEntrys = append(allEntrys, entryM)
+	es.depth++
+	return nil
+}
+
+func (es *encodeState) leave(v reflect.Value) {
+	es.depth--
+	if key, ok := visitOf(v); ok {
+		delete(es.seen, key)
 	}
+}
 
-	builder.WriteRune('{')
-	total := len(allnames) - 1
-	for i, v := range allnames {
-		builder.WriteString(v)
-		if i != total {
-			builder.WriteRune(',')
-		}
+// indentation returns the indentation unit of the encoder.
+func (es *encodeState) indentation() string {
+	if es.indent <= 0 {
+		return Indentation
 	}
-	builder.WriteString("}:\n")
-
-	for _, entrys := range allEntrys {
-		total = len(entrys) - 1
-		builder.WriteString(Indentation)
-		j := 0
-		total := len(allnames) - 1
-		for _, name := range allnames {
-			v, exists := entrys[name]
-			if !exists {
-				builder.WriteString("null")
-			} else {
-				s, err := marshalSolve(v, v.Type())
-				if err != nil {
-					return "", err
-				}
-				builder.WriteString(s)
-			}
-			if j != total {
-				builder.WriteString(",")
-			}
-			j++
-		}
-		builder.WriteByte('\n')
+	return strings.Repeat(" ", es.indent)
+}
+
+// visitOf identifies the memory behind v. Only maps, slices and addressable
+// values can take part in a cycle, so other values are not tracked.
+func visitOf(v reflect.Value) (visit, bool) {
+	switch {
+	case v.Kind() == reflect.Map && !v.IsNil():
+		return visit{ptr: v.UnsafePointer(), typ: v.Type()}, true
+	case v.Kind() == reflect.Slice && !v.IsNil():
+		return visit{ptr: v.UnsafePointer(), typ: v.Type(), len: v.Len()}, true
+	case v.CanAddr():
+		return visit{ptr: v.Addr().UnsafePointer(), typ: v.Type()}, true
 	}
+	return visit{}, false
+}
 
-	return builder.String(), nil
+// marshalScalar formats values whose Go kind is a struct or a sequence but
+// that TOON represents as a single scalar, reporting whether v was one of them.
+func (es *encodeState) marshalScalar(v reflect.Value, format string) (string, bool) {
+	if s, ok := es.marshalTime(v, format); ok {
+		return s, true
+	}
+	return es.marshalBytes(v, format)
+}
 
+// isScalarType reports whether values of type t are handled by marshalScalar.
+func isScalarType(t reflect.Type) bool {
+	return isTimeType(t) || isBytesType(t)
+}
+
+// formatString renders s as a TOON string value, quoting and escaping it
+// when it would otherwise decode as a number, boolean or null, or when it
+// contains characters that are significant in TOON.
+func formatString(s string) string {
+	if s == "" || s == "true" || s == "false" || s == "null" ||
+		strings.ContainsAny(s, "0123456789:,{}[]\"|\\-\t\n\r") ||
+		strings.HasPrefix(s, " ") || strings.HasSuffix(s, " ") {
+		return quote(s)
+	}
+	return s
 }
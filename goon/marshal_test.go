@@ -1,13 +1,38 @@
 package goon_test
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net/netip"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/roboogg133/goon/goon"
 )
 
+// node writes itself with the methods goon-gen would write for it.
+type node struct {
+	Name   string `toon:"name"`
+	Parent *node  `toon:"parent"`
+}
+
+func (n node) AppendTOONObject(w *goon.ObjectWriter, dst []byte) ([]byte, error) {
+	dst = w.Indent(dst, 0)
+	dst = append(dst, "name : "...)
+	dst = goon.AppendString(dst, n.Name)
+	dst = append(dst, '\n')
+
+	dst = w.Indent(dst, 0)
+	if n.Parent == nil {
+		return append(dst, "parent : null\n"...), nil
+	}
+	dst = append(dst, "parent :\n"...)
+	return w.AppendObject(dst, n.Parent)
+}
+
 type Test1 struct {
 	ID     int     `toon:"id"`
 	Name   *string `toon:"name"`
@@ -196,4 +221,376 @@ func TestMarshal(t *testing.T) {
 		fmt.Println(string(a))
 	})
 
+	t.Run("non-string map keys", func(t *testing.T) {
+		ints := map[int]string{10: "ten", 2: "two", -1: "minus one"}
+		a, err := goon.Marshal(ints)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "\"-1\" : minus one\n\"10\" : ten\n\"2\" : two"
+		if string(a) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", a, want)
+		}
+
+		addrs := map[netip.Addr]string{
+			netip.MustParseAddr("10.0.0.2"): "db",
+			netip.MustParseAddr("10.0.0.1"): "web",
+		}
+		a, err = goon.Marshal(addrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = "\"10.0.0.1\" : web\n\"10.0.0.2\" : db"
+		if string(a) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", a, want)
+		}
+
+		keys := map[string]int{"my key": 1, "plain": 2}
+		a, err = goon.Marshal(keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = "\"my key\" : 1\nplain : 2"
+		if string(a) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", a, want)
+		}
+	})
+
+	t.Run("cycles", func(t *testing.T) {
+		type Node struct {
+			Name   string `toon:"name"`
+			Parent *Node  `toon:"parent"`
+		}
+		a := &Node{Name: "a"}
+		b := &Node{Name: "b", Parent: a}
+		a.Parent = b
+
+		var unsupported *goon.UnsupportedValueError
+
+		_, err := goon.Marshal(*a)
+		if !errors.As(err, &unsupported) {
+			t.Errorf("pointer cycle: got %v, want an UnsupportedValueError", err)
+		}
+
+		m := map[string]any{"name": "self"}
+		m["self"] = m
+		_, err = goon.Marshal(m)
+		if !errors.As(err, &unsupported) {
+			t.Errorf("map cycle: got %v, want an UnsupportedValueError", err)
+		}
+
+		s := []any{1, nil}
+		s[1] = s
+		_, err = goon.Marshal(s)
+		if !errors.As(err, &unsupported) {
+			t.Errorf("slice cycle: got %v, want an UnsupportedValueError", err)
+		}
+
+		shared := map[string]int{"n": 1}
+		_, err = goon.Marshal(map[string]any{"a": shared, "b": shared})
+		if err != nil {
+			t.Errorf("shared value reported as a cycle: %v", err)
+		}
+	})
+
+	t.Run("generated cycles", func(t *testing.T) {
+		a := &node{Name: "a"}
+		a.Parent = a
+		var unsupported *goon.UnsupportedValueError
+		if _, err := goon.Marshal(a); !errors.As(err, &unsupported) {
+			t.Errorf("self-reference: got %v, want an UnsupportedValueError", err)
+		}
+		if _, err := goon.Marshal(map[string]any{"n": a}); !errors.As(err, &unsupported) {
+			t.Errorf("nested self-reference: got %v, want an UnsupportedValueError", err)
+		}
+
+		b := &node{Name: "b", Parent: &node{Name: "c"}}
+		got, err := goon.Marshal([]*node{b, b})
+		want := "[2]:\n  - name : b\n    parent :\n      name : c\n      parent : null\n  - name : b\n    parent :\n      name : c\n      parent : null"
+		if err != nil || string(got) != want {
+			t.Errorf("shared value: got %v\n%s\nwant:\n%s", err, got, want)
+		}
+
+		enc := goon.NewEncoder(io.Discard)
+		enc.SetMaxDepth(2)
+		if err := enc.Encode(b); err != nil {
+			t.Error(err)
+		}
+		if err := enc.Encode(&node{Parent: b}); !errors.As(err, &unsupported) {
+			t.Errorf("max depth: got %v, want an UnsupportedValueError", err)
+		}
+	})
+
+	t.Run("max depth", func(t *testing.T) {
+		enc := goon.NewEncoder(io.Discard)
+		enc.SetMaxDepth(2)
+
+		var unsupported *goon.UnsupportedValueError
+		if err := enc.Encode(test2); !errors.As(err, &unsupported) {
+			t.Errorf("got %v, want an UnsupportedValueError", err)
+		}
+
+		enc.SetMaxDepth(3)
+		if err := enc.Encode(test2); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("nil values", func(t *testing.T) {
+		type Nils struct {
+			Ptr      *int           `toon:"ptr"`
+			Omitted  *int           `toon:"omitted" omitempty:"true"`
+			Iface    any            `toon:"iface"`
+			Slice    []string       `toon:"slice"`
+			Map      map[string]int `toon:"map"`
+			Pointers []*int         `toon:"pointers"`
+			Mixed    []any          `toon:"mixed"`
+		}
+		one := 1
+		v := Nils{
+			Pointers: []*int{&one, nil},
+			Mixed:    []any{nil, "a"},
+		}
+
+		a, err := goon.Marshal(&v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `ptr : null
+iface : null
+slice[0]:
+map :
+pointers[2]: 1,null
+mixed[2]:
+  - null
+  - a`
+		if string(a) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", a, want)
+		}
+
+		var buf strings.Builder
+		enc := goon.NewEncoder(&buf)
+		enc.SetNilSliceAsNull(true)
+		enc.SetNilMapAsNull(true)
+		if err := enc.Encode(Nils{}); err != nil {
+			t.Fatal(err)
+		}
+		want = `ptr : null
+iface : null
+slice : null
+map : null
+pointers : null
+mixed : null
+`
+		if buf.String() != want {
+			t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+		}
+
+		for _, root := range []any{nil, (*Test1)(nil)} {
+			a, err := goon.Marshal(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(a) != "null" {
+				t.Errorf("Marshal(%#v) = %q, want null", root, a)
+			}
+		}
+	})
+
+	t.Run("tabular arrays", func(t *testing.T) {
+		type Person struct {
+			Name  string `toon:"name"`
+			Age   int64  `toon:"age"`
+			Admin bool   `toon:"admin"`
+		}
+		people := []Person{{"Ada", 36, true}, {"Alan", 41, false}}
+		rows := "{name,age,admin}:\n  Ada,36,true\n  Alan,41,false"
+
+		tests := []struct {
+			name string
+			v    any
+			want string
+		}{
+			{"root", people, "[2]" + rows},
+			{"pointers", []*Person{&people[0], &people[1]}, "[2]" + rows},
+			{"map", map[string][]Person{"people": people}, "people[2]" + rows},
+			{"interfaces", map[string]any{"people": []any{
+				map[string]any{"admin": true, "age": 36, "name": "Ada"},
+				map[string]any{"admin": false, "age": 41, "name": "Alan"},
+			}}, "people[2]{admin,age,name}:\n  true,36,Ada\n  false,41,Alan"},
+			{"nested", struct {
+				Team struct {
+					People []Person `toon:"people"`
+				} `toon:"team"`
+			}{struct {
+				People []Person `toon:"people"`
+			}{people}}, "team :\n  people[2]" + strings.ReplaceAll(rows, "\n", "\n  ")},
+			{"list item", [][]Person{people}, "[1]:\n  - [2]" + strings.ReplaceAll(rows, "\n", "\n  ")},
+			{"non-uniform keys", []map[string]int{{"a": 1}, {"b": 2}}, "[2]:\n  - a : 1\n  - b : 2"},
+			{"non-primitive values", []map[string][]int{{"a": {1, 2}}}, "[1]:\n  - a[2]: 1,2"},
+		}
+
+		for _, tt := range tests {
+			a, err := goon.Marshal(tt.v)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if string(a) != tt.want {
+				t.Errorf("%s: got:\n%s\nwant:\n%s", tt.name, a, tt.want)
+			}
+		}
+	})
+
+	t.Run("indent", func(t *testing.T) {
+		type Person struct {
+			Name string `toon:"name"`
+			Age  int    `toon:"age"`
+		}
+		v := map[string]any{
+			"team": map[string]any{
+				"people": []Person{{"Ada", 36}},
+				"tags":   []any{"a", []int{1, 2}, map[string]int{"x": 1, "y": 2}},
+			},
+		}
+		want := `team :
+    people[1]{name,age}:
+        Ada,36
+    tags[3]:
+        - a
+        - [2]: 1,2
+        - x : 1
+            y : 2
+`
+		var buf strings.Builder
+		enc := goon.NewEncoder(&buf)
+		enc.SetIndent(4)
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != want {
+			t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+		}
+	})
+
+	t.Run("unsupported types", func(t *testing.T) {
+		for _, v := range []any{
+			make(chan int),
+			struct {
+				F func() `toon:"f"`
+			}{},
+			[]any{1, complex(1, 2)},
+		} {
+			_, err := goon.Marshal(v)
+			var typeErr *goon.UnsupportedTypeError
+			if !errors.As(err, &typeErr) {
+				t.Errorf("Marshal(%T): got %v, want an UnsupportedTypeError", v, err)
+			}
+		}
+	})
+
+	t.Run("floats", func(t *testing.T) {
+		got, err := goon.Marshal(struct {
+			F32 float32   `toon:"f32"`
+			F64 float64   `toon:"f64"`
+			All []float32 `toon:"all"`
+		}{0.1, 0.1, []float32{1.1, 1e-7}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "f32 : 0.1\nf64 : 0.1\nall[2]: 1.1,1e-07"; string(got) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+
+		nan, inf := math.NaN(), math.Inf(1)
+		for _, v := range []any{
+			nan,
+			float32(-inf),
+			[]float64{1, inf},
+			map[string]any{"x": nan},
+			[]struct {
+				F float64 `toon:"f"`
+			}{{1}, {nan}},
+		} {
+			_, err := goon.Marshal(v)
+			var valueErr *goon.UnsupportedValueError
+			if !errors.As(err, &valueErr) {
+				t.Errorf("Marshal(%v): got %v, want an UnsupportedValueError", v, err)
+			}
+		}
+	})
+
+	t.Run("append", func(t *testing.T) {
+		prefix := []byte("data:\n")
+		got, err := goon.AppendMarshal(prefix, test1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := goon.Marshal(test1)
+		if string(got) != "data:\n"+string(want) {
+			t.Errorf("got:\n%s\nwant:\ndata:\n%s", got, want)
+		}
+
+		got, err = goon.AppendMarshal(prefix, make(chan int))
+		if err == nil || string(got) != "data:\n" {
+			t.Errorf("got %q, %v, want the prefix and an error", got, err)
+		}
+	})
+
+	t.Run("buffer reuse", func(t *testing.T) {
+		small := Test4{Tags: []string{"a", "b"}, Numbers: []int{1, 2}}
+		enc := goon.NewEncoder(io.Discard)
+		enc.Encode(small)
+		allocs := testing.AllocsPerRun(100, func() {
+			if err := enc.Encode(small); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if allocs > 2 {
+			t.Errorf("Encode allocated %v times per call, want at most 2", allocs)
+		}
+
+		// A buffer returned by Marshal must not be reused by later calls.
+		first, _ := goon.Marshal(small)
+		want := string(first)
+		goon.Marshal(test1)
+		if string(first) != want {
+			t.Errorf("Marshal result changed to %q", first)
+		}
+	})
+}
+
+func BenchmarkMarshalTable(b *testing.B) {
+	type Row struct {
+		ID     int     `toon:"id"`
+		Name   string  `toon:"name"`
+		Score  float64 `toon:"score"`
+		Active bool    `toon:"active"`
+	}
+	rows := make([]Row, 50000)
+	for i := range rows {
+		rows[i] = Row{i, fmt.Sprintf("user%d", i), float64(i) / 3, i%2 == 0}
+	}
+	v := struct {
+		Rows []Row `toon:"rows"`
+	}{rows}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := goon.Marshal(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeSmall(b *testing.B) {
+	fd := "Ada Lovelace"
+	v := Test1{ID: 123, Name: &fd, Active: true, Email: "ada@example.com", Score: 98.5}
+	enc := goon.NewEncoder(io.Discard)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := enc.Encode(v); err != nil {
+			b.Fatal(err)
+		}
+	}
 }
@@ -0,0 +1,263 @@
+package goon
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Marshaler is the interface implemented by types that can marshal
+// themselves into TOON, such as the types for which goon-gen generated
+// methods. The document returned by MarshalTOON is inserted where the value
+// appears, indented to its position.
+//
+// The elements of a tabular array are always written from their fields, so
+// MarshalTOON is not called for them.
+type Marshaler interface {
+	MarshalTOON() ([]byte, error)
+}
+
+// ObjectMarshaler is the interface implemented by types that append the keys
+// of an object to a document being encoded, such as the types for which
+// goon-gen generated methods. Marshal and Encoder prefer it to Marshaler: the
+// writer applies the options of the encoder, and detects cycles and limits
+// the nesting depth across the objects it writes like Marshal does.
+// AppendTOONObject appends the keys to dst and returns the extended buffer.
+type ObjectMarshaler interface {
+	AppendTOONObject(w *ObjectWriter, dst []byte) ([]byte, error)
+}
+
+// Unmarshaler is the interface implemented by types that can unmarshal a
+// TOON description of themselves. UnmarshalTOON receives the keys nested
+// below the key of the value, as a document of its own, or the whole document
+// for the value passed to Unmarshal.
+//
+// The rows of a tabular array are always decoded into the fields of their
+// elements, so UnmarshalTOON is not called for them.
+type Unmarshaler interface {
+	UnmarshalTOON(data []byte) error
+}
+
+// ObjectUnmarshaler is the interface implemented by types that decode the
+// keys of an object from an ObjectReader, such as the types for which
+// goon-gen generated methods. Unmarshal and Decoder prefer it to Unmarshaler:
+// the reader applies the options and limits of the decoder, and the unknown
+// and missing keys it records are reported along with the others of the
+// document. UnmarshalTOONObject must not call Close.
+type ObjectUnmarshaler interface {
+	UnmarshalTOONObject(r *ObjectReader) error
+}
+
+var (
+	marshalerType         = reflect.TypeFor[Marshaler]()
+	objectMarshalerType   = reflect.TypeFor[ObjectMarshaler]()
+	unmarshalerType       = reflect.TypeFor[Unmarshaler]()
+	objectUnmarshalerType = reflect.TypeFor[ObjectUnmarshaler]()
+)
+
+// newMarshalerEncoder returns the encoder of the type t, which implements
+// Marshaler, or whose pointer type does. The latter is only called for
+// addressable values and other values use the encoder of kind.
+func newMarshalerEncoder(t reflect.Type, fallback *typeEncoder) *typeEncoder {
+	sh := primitiveShape
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		sh = objectShape
+	case reflect.Array, reflect.Slice:
+		if !isBytesType(t) {
+			sh = arrayShape
+		}
+	}
+
+	addr := !t.Implements(marshalerType)
+	return &typeEncoder{sh, func(es *encodeState, v reflect.Value, format string) error {
+		if addr {
+			if !v.CanAddr() {
+				return fallback.encode(es, v, format)
+			}
+			v = v.Addr()
+		}
+		if !v.CanInterface() {
+			return fallback.encode(es, v, format)
+		}
+
+		data, err := v.Interface().(Marshaler).MarshalTOON()
+		if err != nil {
+			return fmt.Errorf("goon: error calling MarshalTOON for type %s: %w", t, err)
+		}
+		es.appendDocument(data, sh)
+		return nil
+	}}
+}
+
+// appendDocument inserts the document data, written by a Marshaler, at the
+// current position. The lines of an object are written at the current level,
+// and the lines of an array following its header one level below. Their
+// indentation, inferred like the decoder does, is rewritten with the
+// indentation unit of the encoder.
+func (es *encodeState) appendDocument(data []byte, sh shape) {
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	if sh == primitiveShape {
+		es.buf = append(es.buf, data...)
+		return
+	}
+
+	unit := detectIndent(data)
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if sh == objectShape {
+			es.newline()
+		} else if i > 0 {
+			es.level++
+			es.newline()
+			es.level--
+		}
+		if unit != len(es.unit) {
+			levels := (len(line) - len(bytes.TrimLeft(line, " "))) / unit
+			for range levels {
+				es.buf = append(es.buf, es.unit...)
+			}
+			line = line[levels*unit:]
+		}
+		es.buf = append(es.buf, line...)
+		es.buf = append(es.buf, '\n')
+	}
+}
+
+// readBlock reads the lines that follow, up to the first one that is not
+// nested at depth or below, and returns them as a document of its own.
+func (ds *decodeState) readBlock(scanner *lineScanner, depth int) ([]byte, error) {
+	var doc []byte
+	for scanner.Scan() {
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		d, err := ds.depthOf(text, scanner.line)
+		if err != nil {
+			return nil, err
+		}
+		if d < depth || strings.HasPrefix(text, string(rune(3))) {
+			scanner.Unscan()
+			break
+		}
+		doc = append(doc, text[min(depth*ds.indent, calcIndent(text)):]...)
+		doc = append(doc, '\n')
+	}
+	return doc, scanner.Err()
+}
+
+// unmarshalerFor returns the Unmarshaler or ObjectUnmarshaler the nested
+// object name of obj is decoded by, when its destination implements one.
+func (ds *decodeState) unmarshalerFor(obj *object, name string) (dst reflect.Value, commit func(), ok bool) {
+	switch obj.value.Kind() {
+	case reflect.Struct:
+		a, exists := lookupField(obj.fields, name, ds.caseInsensitive)
+		if !exists {
+			return reflect.Value{}, nil, false
+		}
+		dst = obj.value.Field(a.Pos)
+	case reflect.Map:
+		m := obj.value
+		key, err := decodeMapKey(m.Type().Key(), name)
+		if err != nil {
+			return reflect.Value{}, nil, false
+		}
+		elem := reflect.New(m.Type().Elem()).Elem()
+		dst = elem
+		commit = func() { m.SetMapIndex(key, elem) }
+	default:
+		return reflect.Value{}, nil, false
+	}
+
+	if dst, ok = unmarshalerOf(dst); !ok {
+		return reflect.Value{}, nil, false
+	}
+	return dst, commit, true
+}
+
+// unmarshalerOf returns dst, through any number of pointers, which are
+// allocated, when its address implements Unmarshaler or ObjectUnmarshaler.
+func unmarshalerOf(dst reflect.Value) (reflect.Value, bool) {
+	for dst.Kind() == reflect.Pointer {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
+	if !dst.CanAddr() {
+		return reflect.Value{}, false
+	}
+	if t := dst.Addr().Type(); !t.Implements(objectUnmarshalerType) && !t.Implements(unmarshalerType) {
+		return reflect.Value{}, false
+	}
+	return dst, true
+}
+
+// callUnmarshaler decodes the keys nested at depth that follow in scanner
+// with the UnmarshalTOONObject or UnmarshalTOON method of dst, which is found
+// at path. The unknown and missing keys it reports are collected along with
+// the others of the document.
+func (ds *decodeState) callUnmarshaler(dst reflect.Value, scanner *lineScanner, depth int, path string) error {
+	if u, ok := dst.Addr().Interface().(ObjectUnmarshaler); ok {
+		r := ds.objectReader(scanner, depth, path)
+		if err := u.UnmarshalTOONObject(r); err != nil {
+			return err
+		}
+		// Skip the keys left unread, which belong to the object.
+		for r.Next() {
+		}
+		return r.Err()
+	}
+
+	data, err := ds.readBlock(scanner, depth)
+	if err != nil {
+		return err
+	}
+	err = dst.Addr().Interface().(Unmarshaler).UnmarshalTOON(data)
+	fieldErr, ok := err.(*FieldError)
+	if !ok {
+		return err
+	}
+	for _, name := range fieldErr.Unknown {
+		ds.unknown = append(ds.unknown, joinPath(path, name))
+	}
+	for _, name := range fieldErr.Missing {
+		ds.missing = append(ds.missing, joinPath(path, name))
+	}
+	return nil
+}
+
+// AppendString appends s to dst as a TOON string value, quoted when needed.
+func AppendString(dst []byte, s string) []byte {
+	return append(dst, formatString(s)...)
+}
+
+// AppendFloat appends f to dst as a TOON number, as Marshal writes a float of
+// the given bit size, 32 or 64. NaN and infinities, which TOON can't
+// represent, fail with an *UnsupportedValueError.
+func AppendFloat(dst []byte, f float64, bits int) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return dst, &UnsupportedValueError{reflect.ValueOf(f), strconv.FormatFloat(f, 'g', -1, 64)}
+	}
+	return strconv.AppendFloat(dst, f, 'g', -1, bits), nil
+}
+
+// AppendKey appends name to dst as a TOON key, quoted when needed.
+func AppendKey(dst []byte, name string) []byte {
+	return append(dst, formatKey(name)...)
+}
+
+// DecodeDefault decodes def, the `default` tag of the struct field name, into
+// the value pointed to by dst, the way Unmarshal does for missing keys. It is
+// used by the code generated by goon-gen.
+func DecodeDefault(dst any, name, def, format string) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("goon: invalid default destination %T", dst)
+	}
+	ds := &decodeState{}
+	return ds.decodeDefault(rv.Elem(), name, def, format)
+}
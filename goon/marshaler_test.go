@@ -0,0 +1,250 @@
+package goon_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/roboogg133/goon/goon"
+)
+
+// point encodes itself with the methods goon-gen would write for it.
+type point struct {
+	X, Y int
+}
+
+func (p point) MarshalTOON() ([]byte, error) {
+	dst := append([]byte("x : "), strconv.Itoa(p.X)...)
+	dst = append(dst, "\ny : "...)
+	return append(dst, strconv.Itoa(p.Y)...), nil
+}
+
+func (p *point) UnmarshalTOON(data []byte) error {
+	r := goon.NewObjectReader(data)
+	seen := false
+	for r.Next() {
+		var err error
+		switch r.Key() {
+		case "x":
+			seen = true
+			var n int64
+			n, err = r.Int(0)
+			p.X = int(n)
+		case "y":
+			var n int64
+			n, err = r.Int(0)
+			p.Y = int(n)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !seen {
+		r.Missing("x")
+	}
+	return r.Close()
+}
+
+// box writes a nested object in the indentation of Marshal.
+type box struct{}
+
+func (box) MarshalTOON() ([]byte, error) {
+	return []byte("in :\n  x : 1\n  deep :\n    y : 2"), nil
+}
+
+type shape struct {
+	Name   string  `toon:"name"`
+	Origin point   `toon:"origin"`
+	Corner *point  `toon:"corner"`
+	Points []point `toon:"points"`
+}
+
+func TestMarshaler(t *testing.T) {
+	v := shape{
+		Name:   "box",
+		Origin: point{1, 2},
+		Corner: &point{3, 4},
+		Points: []point{{5, 6}},
+	}
+	want := "name : box\norigin :\n  x : 1\n  y : 2\ncorner :\n  x : 3\n  y : 4\npoints[1]:\n  - x : 5\n    y : 6"
+
+	t.Run("marshal", func(t *testing.T) {
+		got, err := goon.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("unmarshal", func(t *testing.T) {
+		v.Points = nil
+		var got shape
+		if err := goon.Unmarshal([]byte("name : box\norigin :\n  x : 1\n  y : 2\ncorner :\n  x : 3\n  y : 4"), &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, v) {
+			t.Errorf("got %+v, want %+v", got, v)
+		}
+	})
+
+	t.Run("missing keys", func(t *testing.T) {
+		var got shape
+		err := goon.Unmarshal([]byte("origin :\n  y : 2\ncorner :\n  x : 3"), &got)
+		var fieldErr *goon.FieldError
+		if !errors.As(err, &fieldErr) || !reflect.DeepEqual(fieldErr.Missing, []string{"origin.x"}) {
+			t.Fatalf("got %v, want missing origin.x", err)
+		}
+	})
+
+	t.Run("indent", func(t *testing.T) {
+		var buf bytes.Buffer
+		enc := goon.NewEncoder(&buf)
+		enc.SetIndent(4)
+		if err := enc.Encode(map[string]box{"m": {}}); err != nil {
+			t.Fatal(err)
+		}
+		want := "m :\n    in :\n        x : 1\n        deep :\n            y : 2\n"
+		if buf.String() != want {
+			t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+		}
+		var got map[string]map[string]map[string]any
+		if err := goon.Unmarshal(buf.Bytes(), &got); err != nil || fmt.Sprint(got) != "map[m:map[in:map[deep:map[y:2] x:1]]]" {
+			t.Errorf("got %v, %v", got, err)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var got shape
+		if err := goon.Unmarshal([]byte("origin :\n  x : abc"), &got); err == nil {
+			t.Error("expected an error for a string coordinate")
+		}
+	})
+}
+
+func TestObjectReader(t *testing.T) {
+	data := `name : "a, b"
+count : 3
+ratio : null
+tags[2]: x,y
+list[2]:
+  - 1
+  - 2
+rows[2]{id,ok}:
+  1,true
+  2,false
+nested :
+  inner : 4
+  deep :
+    skipped : 1
+after : true
+extra :
+  a : 1`
+
+	r := goon.NewObjectReader([]byte(data))
+	got := make(map[string]any)
+	for r.Next() {
+		var err error
+		switch key := r.Key(); key {
+		case "name":
+			got[key], err = r.Text()
+		case "count":
+			got[key], err = r.Int(8)
+		case "ratio":
+			got[key] = r.IsNull()
+		case "tags", "list":
+			var values []goon.Value
+			values, err = r.List()
+			var texts []string
+			for _, v := range values {
+				texts = append(texts, v.String())
+			}
+			got[key] = texts
+		case "rows":
+			var table *goon.Table
+			table, err = r.Table()
+			if err == nil {
+				id, _ := table.Rows[1][0].Int(0)
+				ok, _ := table.Rows[1][1].Bool()
+				got[key] = []any{table.Header, id, ok}
+			}
+		case "nested":
+			var obj *goon.ObjectReader
+			if obj, err = r.Object(); err == nil && obj.Next() {
+				got[key], err = obj.Uint(0)
+			}
+		case "extra":
+			var m map[string]int
+			err = r.Decode(&m, "")
+			got[key] = m
+		case "after":
+			got[key], err = r.Bool()
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", r.Key(), err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"name":   "a, b",
+		"count":  int64(3),
+		"ratio":  true,
+		"tags":   []string{"x", "y"},
+		"list":   []string{"1", "2"},
+		"rows":   []any{[]string{"id", "ok"}, int64(2), false},
+		"nested": uint64(4),
+		"after":  true,
+		"extra":  map[string]int{"a": 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+
+	t.Run("lists of objects", func(t *testing.T) {
+		data := []byte("items[2]:\n  - a : 1\n    b : 2\n  - c : 3\n")
+		r := goon.NewObjectReader(data)
+		if !r.Next() {
+			t.Fatal(r.Err())
+		}
+		if _, err := r.List(); err == nil {
+			t.Error("List: expected an error for a list of objects")
+		}
+
+		r = goon.NewObjectReader(data)
+		var items []map[string]int
+		if !r.Next() {
+			t.Fatal(r.Err())
+		}
+		if err := r.Decode(&items, ""); err != nil {
+			t.Fatal(err)
+		}
+		if want := []map[string]int{{"a": 1, "b": 2}, {"c": 3}}; !reflect.DeepEqual(items, want) {
+			t.Errorf("got %v, want %v", items, want)
+		}
+	})
+
+	t.Run("conversions", func(t *testing.T) {
+		r := goon.NewObjectReader([]byte("big : 300\nneg : -1\ntext : abc"))
+		for r.Next() {
+			var err error
+			switch r.Key() {
+			case "big":
+				_, err = r.Int(8)
+			case "neg":
+				_, err = r.Uint(0)
+			case "text":
+				_, err = r.Float(64)
+			}
+			if err == nil {
+				t.Errorf("%s: expected an error", r.Key())
+			}
+		}
+	})
+}
@@ -0,0 +1,688 @@
+package goon
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// An ObjectReader reads the keys of a TOON object one at a time, without
+// reflection. It is the building block of the UnmarshalTOON methods written
+// by goon-gen and reads documents the way Unmarshal does.
+//
+// Next advances to the next key of the object, whose value is then read with
+// one of the other methods. Values that are not read are skipped.
+//
+// The readers passed to ObjectUnmarshaler methods apply the options and
+// limits of the Decoder: unknown keys, recorded by Unknown, are reported when
+// it disallows them, Field matches keys ignoring case when it is set to, and
+// documents exceeding its Limits are rejected.
+type ObjectReader struct {
+	doc   *document
+	depth int
+	path  string
+	// keys is the number of keys read so far, for Limits.MaxKeys.
+	keys int
+
+	key   string
+	value string
+	// text is the line of the current key and rest what follows the key.
+	text   string
+	rest   string
+	header arrayHeader
+	array  bool
+}
+
+// document is the state shared by the readers of a single document: the
+// decodeState holding the options of the decoder and collecting unknown and
+// missing keys, and the scanner over the lines of the document.
+type document struct {
+	*decodeState
+	scanner *lineScanner
+	eof     bool
+	err     error
+}
+
+// NewObjectReader returns a reader for the top-level keys of the document
+// data, with the options of Unmarshal.
+func NewObjectReader(data []byte) *ObjectReader {
+	ds := &decodeState{indent: detectIndent(data)}
+	return ds.objectReader(newLineScanner(data, 0), 0, "")
+}
+
+// objectReader returns a reader for the keys nested at depth in the document
+// read by scanner, the keys of the object at path.
+func (ds *decodeState) objectReader(scanner *lineScanner, depth int, path string) *ObjectReader {
+	return &ObjectReader{doc: &document{decodeState: ds, scanner: scanner}, depth: depth, path: path}
+}
+
+// peek returns the next non-blank line of the document and its depth,
+// leaving it to be consumed with scanner.Scan.
+func (d *document) peek() (string, int, bool) {
+	for !d.eof && d.err == nil && d.scanner.Scan() {
+		text := d.scanner.Text()
+		if strings.HasPrefix(text, string(rune(3))) {
+			d.scanner.Unscan()
+			d.eof = true
+			break
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		d.scanner.Unscan()
+		depth, err := d.depthOf(text, d.scanner.line)
+		if err != nil {
+			d.err = err
+			break
+		}
+		return text, depth, true
+	}
+	if d.err == nil {
+		d.err = d.scanner.Err()
+	}
+	return "", 0, false
+}
+
+func (d *document) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// Next advances to the next key of the object. It returns false at the end
+// of the object or when an error occurs, which Err then reports.
+func (r *ObjectReader) Next() bool {
+	for {
+		text, depth, ok := r.doc.peek()
+		if !ok || depth < r.depth {
+			return false
+		}
+		r.doc.scanner.Scan()
+		if depth > r.depth {
+			// What is left of the value of the previous key.
+			continue
+		}
+		if err := r.checkLimits(); err != nil {
+			r.doc.fail(err)
+			return false
+		}
+
+		parts, err := splitKeyValue(text)
+		if err != nil {
+			r.doc.fail(err)
+			return false
+		}
+		key := strings.TrimSpace(parts[0])
+		r.text = strings.TrimSpace(text)
+		r.value = strings.TrimSpace(parts[1])
+		r.header, r.array, err = parseArrayHeader(key, r.doc.scanner.line)
+		if err != nil {
+			r.doc.fail(err)
+			return false
+		}
+		if r.array {
+			key = r.header.key
+		}
+		r.rest = r.text[len(key):]
+		if r.key, err = parseKey(key); err != nil {
+			r.doc.fail(err)
+			return false
+		}
+		if r.array {
+			if err := r.doc.checkArrayLength(r.header.length, r.doc.scanner.line); err != nil {
+				r.doc.fail(err)
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// checkLimits fails when the key just scanned by Next is nested deeper, or
+// is one key more of its object, than the limits of the decoder allow.
+func (r *ObjectReader) checkLimits() error {
+	line := r.doc.scanner.line
+	if max := r.doc.limits.MaxDepth; max > 0 && r.depth+1 > max {
+		return &LimitError{Limit: "depth", Max: max, Line: line}
+	}
+	r.keys++
+	if max := r.doc.limits.MaxKeys; max > 0 && r.keys > max {
+		return &LimitError{Limit: "keys per object", Max: max, Line: line}
+	}
+	return nil
+}
+
+// Key returns the current key.
+func (r *ObjectReader) Key() string {
+	return r.key
+}
+
+// Err returns the first error encountered while reading the document.
+func (r *ObjectReader) Err() error {
+	return r.doc.err
+}
+
+// Field returns the index in fields of the field the current key is decoded
+// into, or -1 when it matches none. fields lists the key of each field of
+// the struct being decoded followed by its aliases. Keys are matched the way
+// Unmarshal matches them, ignoring case when the decoder is set to, and keys
+// matched through an alias or ignoring case are passed to the alias reporter
+// of the decoder.
+func (r *ObjectReader) Field(fields [][]string) int {
+	return r.doc.match(fields, r.key, r.path)
+}
+
+// match returns the index in fields, as passed to ObjectReader.Field, of the
+// field the key name of the object at path matches, like lookupField.
+func (d *document) match(fields [][]string, name, path string) int {
+	i := slices.IndexFunc(fields, func(keys []string) bool { return keys[0] == name })
+	if i < 0 {
+		i = slices.IndexFunc(fields, func(keys []string) bool { return slices.Contains(keys[1:], name) })
+	}
+	if i < 0 && d.caseInsensitive {
+		i = slices.IndexFunc(fields, func(keys []string) bool {
+			return slices.ContainsFunc(keys, func(key string) bool { return strings.EqualFold(key, name) })
+		})
+	}
+	if i >= 0 && fields[i][0] != name && d.aliasReporter != nil {
+		d.aliasReporter(joinPath(path, fields[i][0]), name)
+	}
+	return i
+}
+
+// Unknown records the current key as unknown, when it doesn't match any
+// field. Unknown keys are reported by Close when the decoder disallows them
+// and ignored otherwise.
+func (r *ObjectReader) Unknown() {
+	if r.doc.disallowUnknownFields {
+		r.doc.unknown = append(r.doc.unknown, joinPath(r.path, r.key))
+	}
+}
+
+// Missing records the required key name of the object as missing.
+func (r *ObjectReader) Missing(name string) {
+	r.doc.missing = append(r.doc.missing, joinPath(r.path, name))
+}
+
+// Close returns the first error encountered while reading the document or,
+// when there was none, a *FieldError listing the keys recorded by Unknown and
+// Missing.
+func (r *ObjectReader) Close() error {
+	if r.doc.err != nil {
+		return r.doc.err
+	}
+	if len(r.doc.unknown) > 0 || len(r.doc.missing) > 0 {
+		return &FieldError{Unknown: r.doc.unknown, Missing: r.doc.missing}
+	}
+	return nil
+}
+
+// IsNull reports whether the value of the current key is `null`.
+func (r *ObjectReader) IsNull() bool {
+	return !r.array && r.value == "null"
+}
+
+// scalar returns the value of the current key, failing when it is an object
+// or an array.
+func (r *ObjectReader) scalar(target string) (Value, error) {
+	switch {
+	case r.array:
+		return Value{}, fmt.Errorf("goon: trying to assign array to %s", target)
+	case r.value == "":
+		return Value{}, fmt.Errorf("goon: trying to assign object to %s", target)
+	}
+	return Value{r.value}, nil
+}
+
+// Text returns the value of the current key, which must be a string.
+func (r *ObjectReader) Text() (string, error) {
+	v, err := r.scalar("string")
+	if err != nil {
+		return "", err
+	}
+	return v.Text()
+}
+
+// Int returns the value of the current key as a signed integer of the given
+// bit size, 0 meaning the size of int.
+func (r *ObjectReader) Int(bits int) (int64, error) {
+	v, err := r.scalar("int")
+	if err != nil {
+		return 0, err
+	}
+	return v.Int(bits)
+}
+
+// Uint returns the value of the current key as an unsigned integer of the
+// given bit size, 0 meaning the size of uint.
+func (r *ObjectReader) Uint(bits int) (uint64, error) {
+	v, err := r.scalar("uint")
+	if err != nil {
+		return 0, err
+	}
+	return v.Uint(bits)
+}
+
+// Float returns the value of the current key as a float of the given bit
+// size, 32 or 64.
+func (r *ObjectReader) Float(bits int) (float64, error) {
+	v, err := r.scalar("float")
+	if err != nil {
+		return 0, err
+	}
+	return v.Float(bits)
+}
+
+// Bool returns the value of the current key, which must be a boolean.
+func (r *ObjectReader) Bool() (bool, error) {
+	v, err := r.scalar("bool")
+	if err != nil {
+		return false, err
+	}
+	return v.Bool()
+}
+
+// Object returns a reader for the keys of the object nested below the
+// current key.
+func (r *ObjectReader) Object() (*ObjectReader, error) {
+	switch {
+	case r.array:
+		return nil, errors.New("goon: trying to assign array to object")
+	case r.value != "":
+		return nil, fmt.Errorf("goon: trying to assign %s to object", Value{r.value}.kindName())
+	}
+	return &ObjectReader{doc: r.doc, depth: r.depth + 1, path: joinPath(r.path, r.key)}, nil
+}
+
+// List returns the elements of the current value, an inline array or a list
+// of scalars, or nil when it is `null`. Lists of objects or arrays are read
+// with Decode.
+func (r *ObjectReader) List() ([]Value, error) {
+	if r.IsNull() {
+		return nil, nil
+	}
+	if !r.array || r.header.tabular {
+		return nil, r.mismatch("list")
+	}
+
+	if r.value != "" || r.header.length == 0 {
+		if r.value == "" {
+			return []Value{}, nil
+		}
+		parts, err := splitDelimited(r.value, r.header.delim)
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) != r.header.length {
+			return nil, lengthMismatch(r.doc.scanner.line, r.header.length, len(parts))
+		}
+		values := make([]Value, len(parts))
+		for i, p := range parts {
+			if p = strings.TrimSpace(p); p == "" {
+				p = `""`
+			}
+			values[i] = Value{p}
+		}
+		return values, nil
+	}
+
+	values := make([]Value, 0, min(r.header.length, maxPreallocated))
+	for len(values) < r.header.length {
+		if err := r.element(len(values)); err != nil {
+			return nil, err
+		}
+		item, ok := strings.CutPrefix(strings.TrimSpace(r.doc.scanner.Text()), "-")
+		if !ok {
+			continue
+		}
+		// Objects and arrays are left to Decode.
+		item = strings.TrimSpace(item)
+		if _, err := splitKeyValue(item); item == "" || err == nil {
+			return nil, errors.New("goon: trying to assign object or array to list of scalars")
+		}
+		values = append(values, Value{item})
+	}
+	return values, nil
+}
+
+// element scans the line of the element i of the current array, an item of
+// a list or a row of a table, failing when the array ends before it, at the
+// end of the document or at a line that is not nested below the key.
+func (r *ObjectReader) element(i int) error {
+	_, depth, ok := r.doc.peek()
+	switch {
+	case r.doc.err != nil:
+		return r.doc.err
+	case !ok:
+		return unexpectedEOF(r.doc.scanner, r.header.length, i)
+	case depth <= r.depth:
+		return unexpectedEnd(r.doc.scanner.line, r.header.length, i)
+	}
+	r.doc.scanner.Scan()
+	return nil
+}
+
+// Table returns the content of the current value, a tabular array, or nil
+// when it is `null`.
+func (r *ObjectReader) Table() (*Table, error) {
+	if r.IsNull() {
+		return nil, nil
+	}
+	if !r.array || !r.header.tabular || r.value != "" {
+		return nil, r.mismatch("table")
+	}
+
+	header, err := splitDelimited(r.header.fields, r.header.delim)
+	if err != nil {
+		return nil, err
+	}
+	if max := r.doc.limits.MaxKeys; max > 0 && len(header) > max {
+		return nil, &LimitError{Limit: "keys per object", Max: max, Line: r.doc.scanner.line}
+	}
+	for i, f := range header {
+		if header[i], err = parseKey(f); err != nil {
+			return nil, err
+		}
+	}
+
+	t := &Table{
+		Header: header,
+		Rows:   make([][]Value, 0, min(r.header.length, maxPreallocated)),
+		doc:    r.doc,
+		path:   joinPath(r.path, r.key) + "[]",
+	}
+	var cells []Value
+	for i := range r.header.length {
+		if err := r.element(i); err != nil {
+			return nil, err
+		}
+		scanner := r.doc.scanner
+		parts, err := splitDelimited(strings.TrimSpace(scanner.Text()), r.header.delim)
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) != len(header) {
+			return nil, &SyntaxError{msg: fmt.Sprintf("row has %d values but the header declares %d fields", len(parts), len(header)), Line: scanner.line}
+		}
+
+		if len(cells) < len(header) {
+			cells = make([]Value, min(r.header.length-i, maxPreallocated)*len(header))
+		}
+		row := cells[:len(header):len(header)]
+		cells = cells[len(header):]
+		for j, p := range parts {
+			row[j] = Value{strings.TrimSpace(p)}
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t, nil
+}
+
+func (r *ObjectReader) mismatch(target string) error {
+	switch {
+	case r.array && r.header.tabular:
+		return fmt.Errorf("goon: trying to assign table to %s", target)
+	case r.array:
+		return fmt.Errorf("goon: trying to assign list to %s", target)
+	case r.value == "":
+		return fmt.Errorf("goon: trying to assign object to %s", target)
+	}
+	return fmt.Errorf("goon: trying to assign %s to %s", Value{r.value}.kindName(), target)
+}
+
+// Decode decodes the current value into the value pointed to by v the way
+// Unmarshal does for a struct field with the `format` tag format, for values
+// the caller doesn't read itself.
+func (r *ObjectReader) Decode(v any, format string) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("goon: v must be a non-nil pointer")
+	}
+
+	// The current value and what is nested below it make a document of
+	// their own, decoded into the single field of a struct.
+	doc := append([]byte("v"), r.rest...)
+	doc = append(doc, '\n')
+	for {
+		text, depth, ok := r.doc.peek()
+		if !ok || depth <= r.depth {
+			break
+		}
+		r.doc.scanner.Scan()
+		doc = append(doc, text[min(r.depth*r.doc.indent, calcIndent(text)):]...)
+		doc = append(doc, '\n')
+	}
+	if r.doc.err != nil {
+		return r.doc.err
+	}
+
+	tag := `toon:"v"`
+	if format != "" {
+		tag += ` format:` + strconv.Quote(format)
+	}
+	field := reflect.New(reflect.StructOf([]reflect.StructField{
+		{Name: "V", Type: rv.Elem().Type(), Tag: reflect.StructTag(tag)},
+	}))
+	// The keys of the document are renamed from the path of the field to
+	// the path of the current key, and its depth counted from the latter.
+	rename := func(name string) string {
+		return joinPath(r.path, r.key+strings.TrimPrefix(name, "v"))
+	}
+	ds := &decodeState{decOpts: r.doc.decOpts}
+	if ds.limits.MaxDepth > 0 {
+		ds.limits.MaxDepth -= r.depth
+	}
+	if report := ds.aliasReporter; report != nil {
+		ds.aliasReporter = func(path, key string) { report(rename(path), key) }
+	}
+	err := ds.unmarshal(doc, field.Interface())
+	if fieldErr, ok := err.(*FieldError); ok {
+		for _, name := range fieldErr.Unknown {
+			r.doc.unknown = append(r.doc.unknown, rename(name))
+		}
+		for _, name := range fieldErr.Missing {
+			r.doc.missing = append(r.doc.missing, rename(name))
+		}
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	rv.Elem().Set(field.Elem().Field(0))
+	return nil
+}
+
+// A Table is the content of a tabular array read by an ObjectReader: its
+// header and its rows, whose cells are in the order of the header.
+type Table struct {
+	Header []string
+	Rows   [][]Value
+
+	doc     *document
+	path    string
+	columns []int
+	unknown map[int]bool
+	missing map[string]bool
+}
+
+// Field returns the index in fields of the field column i of the header is
+// decoded into, or -1 when it matches none, like ObjectReader.Field. The
+// columns are matched once per table.
+func (t *Table) Field(fields [][]string, i int) int {
+	if t.columns == nil {
+		t.columns = make([]int, len(t.Header))
+		for j, name := range t.Header {
+			t.columns[j] = t.doc.match(fields, name, t.path)
+		}
+	}
+	return t.columns[i]
+}
+
+// Unknown records column i of the header as unknown, when it doesn't match
+// any field, like ObjectReader.Unknown. Every column is recorded once per
+// table.
+func (t *Table) Unknown(i int) {
+	if !t.doc.disallowUnknownFields || t.unknown[i] {
+		return
+	}
+	if t.unknown == nil {
+		t.unknown = make(map[int]bool)
+	}
+	t.unknown[i] = true
+	t.doc.unknown = append(t.doc.unknown, joinPath(t.path, t.Header[i]))
+}
+
+// Missing records the required field name of the rows as missing from the
+// header. Every field is recorded once per table.
+func (t *Table) Missing(name string) {
+	if t.missing[name] {
+		return
+	}
+	if t.missing == nil {
+		t.missing = make(map[string]bool)
+	}
+	t.missing[name] = true
+	t.doc.missing = append(t.doc.missing, joinPath(t.path, name))
+}
+
+// A Value is a single TOON scalar: the value of a key, an element of an
+// inline array or list, or a cell of a tabular array. Its methods convert it
+// like Unmarshal does for fields of the corresponding kinds; `null` converts
+// to the zero value.
+type Value struct {
+	raw string
+}
+
+// IsNull reports whether v is `null`.
+func (v Value) IsNull() bool {
+	return v.raw == "" || v.raw == "null"
+}
+
+// String returns v as written in the document.
+func (v Value) String() string {
+	return v.raw
+}
+
+func (v Value) kindName() string {
+	sc, err := parseScalar(v.raw)
+	if err != nil {
+		return "string"
+	}
+	return sc.kind.typeName()
+}
+
+// Text returns v, which must be a string.
+func (v Value) Text() (string, error) {
+	sc, err := parseScalar(v.raw)
+	if err != nil {
+		return "", err
+	}
+	switch sc.kind {
+	case nullScalar:
+		return "", nil
+	case stringScalar:
+		return sc.s, nil
+	}
+	return "", fmt.Errorf("goon: trying to assign %s to string", sc.kind.typeName())
+}
+
+// Int returns v as a signed integer of the given bit size, 0 meaning the
+// size of int. Floats are accepted when they hold an integral value.
+func (v Value) Int(bits int) (int64, error) {
+	sc, err := parseScalar(v.raw)
+	if err != nil {
+		return 0, err
+	}
+	if sc.kind == nullScalar {
+		return 0, nil
+	}
+	if bits == 0 {
+		bits = strconv.IntSize
+	}
+
+	n, ok := scalarInt(sc)
+	if !ok || bits < 64 && (n < -1<<(bits-1) || n >= 1<<(bits-1)) {
+		return 0, fmt.Errorf("goon: trying to assign %s to int%d", sc.kind.typeName(), bits)
+	}
+	return n, nil
+}
+
+// Uint returns v as an unsigned integer of the given bit size, 0 meaning the
+// size of uint.
+func (v Value) Uint(bits int) (uint64, error) {
+	sc, err := parseScalar(v.raw)
+	if err != nil {
+		return 0, err
+	}
+	if sc.kind == nullScalar {
+		return 0, nil
+	}
+	if bits == 0 {
+		bits = strconv.IntSize
+	}
+
+	n, ok := scalarInt(sc)
+	if !ok || n < 0 || bits < 64 && uint64(n) >= 1<<bits {
+		return 0, fmt.Errorf("goon: trying to assign %s to uint%d", sc.kind.typeName(), bits)
+	}
+	return uint64(n), nil
+}
+
+// scalarInt returns sc as an int64 when it holds an integral value that
+// fits, like intOf.
+func scalarInt(sc scalar) (int64, bool) {
+	switch sc.kind {
+	case intScalar:
+		return int64(sc.i), true
+	case floatScalar:
+		if sc.f != math.Trunc(sc.f) || sc.f < math.MinInt64 || sc.f >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(sc.f), true
+	}
+	return 0, false
+}
+
+// Float returns v as a float of the given bit size, 32 or 64.
+func (v Value) Float(bits int) (float64, error) {
+	sc, err := parseScalar(v.raw)
+	if err != nil {
+		return 0, err
+	}
+
+	var f float64
+	switch sc.kind {
+	case nullScalar:
+		return 0, nil
+	case intScalar:
+		f = float64(sc.i)
+	case floatScalar:
+		f = sc.f
+	default:
+		return 0, fmt.Errorf("goon: trying to assign %s to float%d", sc.kind.typeName(), bits)
+	}
+	if bits == 32 && math.Abs(f) > math.MaxFloat32 {
+		return 0, fmt.Errorf("goon: trying to assign %s to float32", sc.kind.typeName())
+	}
+	return f, nil
+}
+
+// Bool returns v, which must be a boolean.
+func (v Value) Bool() (bool, error) {
+	sc, err := parseScalar(v.raw)
+	if err != nil {
+		return false, err
+	}
+	switch sc.kind {
+	case nullScalar:
+		return false, nil
+	case boolScalar:
+		return sc.b, nil
+	}
+	return false, fmt.Errorf("goon: trying to assign %s to bool", sc.kind.typeName())
+}
@@ -0,0 +1,328 @@
+package goon
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Schema returns a template of the documents Marshal writes for values of
+// the type of v, to show a language model the shape of the answer it should
+// give. It is SchemaOf for the dynamic type of v.
+func Schema(v any) ([]byte, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil, errors.New("goon: schema of nil")
+	}
+	return schemaOf(t)
+}
+
+// SchemaOf returns a template of the documents Marshal writes for values of
+// type T. Keys are written as Marshal writes them, values are placeholders
+// such as `<string>` or `<time RFC 3339>`, arrays have an `[N]` length and
+// arrays of uniform structs a tabular header such as `users[N]{name,age}:`
+// followed by a single row of placeholders. Fields tagged `required`,
+// `omitempty` or `default` are followed by a `# required`, `# optional` or
+// `# default: value` comment. The keys of maps are written as `<key>`, and a
+// struct, map or slice nested in itself as the placeholder `<Name>` of its
+// type.
+//
+// The template is not a valid TOON document, and describes the fields of the
+// type even when it implements Marshaler.
+func SchemaOf[T any]() ([]byte, error) {
+	return schemaOf(reflect.TypeFor[T]())
+}
+
+// schemaState holds the template being written and the struct, map, slice
+// and array types being described, to stop at recursive types.
+type schemaState struct {
+	buf    []byte
+	level  int
+	inline bool
+	open   []reflect.Type
+}
+
+func schemaOf(t reflect.Type) ([]byte, error) {
+	s := &schemaState{}
+	t = derefType(t)
+	var err error
+	switch {
+	case s.isObject(t):
+		err = s.object(t)
+	case s.isArray(t):
+		err = s.array(t, "")
+	default:
+		var p string
+		if p, err = placeholder(t, ""); err == nil {
+			s.buf = append(s.buf, p...)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(s.buf, []byte("\n")), nil
+}
+
+// derefType returns the type pointed to by t, through any number of
+// pointers.
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// isObject reports whether values of type t are written as objects.
+func (s *schemaState) isObject(t reflect.Type) bool {
+	switch {
+	case isScalarType(t) || slices.Contains(s.open, t):
+		return false
+	case t.Kind() == reflect.Struct:
+		return true
+	}
+	return t.Kind() == reflect.Map
+}
+
+// isArray reports whether values of type t are written as arrays.
+func (s *schemaState) isArray(t reflect.Type) bool {
+	return !isScalarType(t) && !slices.Contains(s.open, t) && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array)
+}
+
+// newline starts a new line at the current level, unless the line of a list
+// item marker is to be continued.
+func (s *schemaState) newline() {
+	if s.inline {
+		s.inline = false
+		return
+	}
+	for range s.level {
+		s.buf = append(s.buf, Indentation...)
+	}
+}
+
+// object writes the keys of the struct or map type t.
+func (s *schemaState) object(t reflect.Type) error {
+	s.open = append(s.open, t)
+	defer func() { s.open = s.open[:len(s.open)-1] }()
+	if t.Kind() == reflect.Map {
+		return s.key("<key>", t.Elem(), "", "")
+	}
+
+	for _, f := range typeFields(t) {
+		if err := s.key(f.key, t.Field(f.index).Type, f.format, fieldMarker(t.Field(f.index))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldMarker returns the comment following the key of the struct field f:
+// "required", "optional", "default: value" or nothing.
+func fieldMarker(f reflect.StructField) string {
+	_, opts, _ := fieldName(f)
+	if def, ok := f.Tag.Lookup("default"); ok {
+		return "default: " + def
+	}
+	switch {
+	case opts.Contains("required"):
+		return "required"
+	case f.Tag.Get("omitempty") != "":
+		return "optional"
+	}
+	return ""
+}
+
+// comment appends the comment marker to the line being written, if any.
+func (s *schemaState) comment(marker string) {
+	if marker != "" {
+		s.buf = append(s.buf, "  # "...)
+		s.buf = append(s.buf, marker...)
+	}
+}
+
+// key writes the key name followed by the template of its values, of type t.
+func (s *schemaState) key(name string, t reflect.Type, format, marker string) error {
+	t = derefType(t)
+	s.newline()
+	s.buf = append(s.buf, name...)
+
+	switch {
+	case s.isObject(t):
+		s.buf = append(s.buf, " :"...)
+		s.comment(marker)
+		s.buf = append(s.buf, '\n')
+		s.level++
+		err := s.object(t)
+		s.level--
+		return err
+	case s.isArray(t):
+		return s.array(t, marker)
+	}
+
+	p, err := placeholder(t, format)
+	if err != nil {
+		return err
+	}
+	s.buf = append(s.buf, " : "...)
+	s.buf = append(s.buf, p...)
+	s.comment(marker)
+	s.buf = append(s.buf, '\n')
+	return nil
+}
+
+// array writes the header of the array type t, after its key, followed by
+// the template of its elements.
+func (s *schemaState) array(t reflect.Type, marker string) error {
+	s.open = append(s.open, t)
+	defer func() { s.open = s.open[:len(s.open)-1] }()
+	elem := derefType(t.Elem())
+	s.buf = append(s.buf, "[N]"...)
+
+	switch {
+	case s.isArray(elem) || s.isObject(elem) && !s.isTable(elem):
+		s.buf = append(s.buf, ':')
+		s.comment(marker)
+		s.buf = append(s.buf, '\n')
+		return s.item(elem)
+
+	case s.isObject(elem):
+		fields := typeFields(elem)
+		keys := make([]string, len(fields))
+		cells := make([]string, len(fields))
+		var markers []string
+		for i, f := range fields {
+			keys[i] = f.key
+			p, err := placeholder(derefType(elem.Field(f.index).Type), f.format)
+			if err != nil {
+				return err
+			}
+			cells[i] = p
+			if m := fieldMarker(elem.Field(f.index)); m != "" {
+				markers = append(markers, f.key+" "+m)
+			}
+		}
+		if marker != "" {
+			markers = slices.Insert(markers, 0, marker)
+		}
+		s.buf = append(s.buf, '{')
+		s.buf = append(s.buf, strings.Join(keys, ",")...)
+		s.buf = append(s.buf, "}:"...)
+		s.comment(strings.Join(markers, ", "))
+		s.buf = append(s.buf, '\n')
+		s.level++
+		s.newline()
+		s.level--
+		s.buf = append(s.buf, strings.Join(cells, ",")...)
+		s.buf = append(s.buf, '\n')
+		return nil
+	}
+
+	p, err := placeholder(elem, "")
+	if err != nil {
+		return err
+	}
+	s.buf = append(s.buf, ": "...)
+	s.buf = append(s.buf, p...)
+	s.buf = append(s.buf, ",..."...)
+	s.comment(marker)
+	s.buf = append(s.buf, '\n')
+	return nil
+}
+
+// isTable reports whether arrays of type t are written as tabular arrays:
+// t is a struct type whose fields are all primitives.
+func (s *schemaState) isTable(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	fields := typeFields(t)
+	return len(fields) > 0 && !slices.ContainsFunc(fields, func(f field) bool { return !f.primitive })
+}
+
+// item writes the template of an element of a list, of type t, after a
+// `- ` marker. The first key of an object continues the line of the marker
+// and the others are nested one level below it.
+func (s *schemaState) item(t reflect.Type) error {
+	s.level++
+	defer func() { s.level-- }()
+	s.newline()
+	s.buf = append(s.buf, "- "...)
+
+	switch {
+	case s.isObject(t):
+		s.level++
+		s.inline = true
+		err := s.object(t)
+		s.level--
+		if s.inline {
+			s.inline = false
+			s.buf = append(s.buf[:len(s.buf)-1], '\n')
+		}
+		return err
+	case s.isArray(t):
+		return s.array(t, "")
+	}
+
+	p, err := placeholder(t, "")
+	if err != nil {
+		return err
+	}
+	s.buf = append(s.buf, p...)
+	s.buf = append(s.buf, '\n')
+	return nil
+}
+
+// placeholder returns the placeholder of a primitive value of type t, which
+// is not a pointer, whose field has the `format` tag format.
+func placeholder(t reflect.Type, format string) (string, error) {
+	switch t {
+	case timeType:
+		switch format {
+		case "":
+			return "<time RFC 3339>", nil
+		case TimeFormatUnix:
+			return "<unix seconds>", nil
+		case TimeFormatUnixMilli:
+			return "<unix milliseconds>", nil
+		case TimeFormatUnixNano:
+			return "<unix nanoseconds>", nil
+		}
+		return "<time " + format + ">", nil
+	case durationType:
+		return "<duration like 1h30m0s>", nil
+	case jsonNumberType:
+		return "<number>", nil
+	}
+	if isBytesType(t) {
+		if format == "" {
+			format = BinaryBase64
+		}
+		return "<" + format + ">", nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "<string>", nil
+	case reflect.Bool:
+		return "<boolean>", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "<integer>", nil
+	case reflect.Float32, reflect.Float64:
+		return "<number>", nil
+	case reflect.Interface:
+		return "<any>", nil
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		// A type nested in itself.
+		switch {
+		case t.Name() != "":
+			return "<" + t.Name() + ">", nil
+		case t.Kind() == reflect.Struct || t.Kind() == reflect.Map:
+			return "<object>", nil
+		}
+		return "<array>", nil
+	}
+	return "", &UnsupportedTypeError{t}
+}
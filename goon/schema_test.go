@@ -0,0 +1,144 @@
+package goon_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roboogg133/goon/goon"
+)
+
+type schemaUser struct {
+	Name string  `toon:"name,required"`
+	Age  int     `toon:"age" default:"18"`
+	Size float64 `toon:"size"`
+}
+
+type schemaNode struct {
+	Name     string       `toon:"name"`
+	Children []schemaNode `toon:"children"`
+}
+
+type schemaReply struct {
+	Answer  string           `toon:"answer,required"`
+	Email   *string          `toon:"email" omitempty:"true"`
+	Tags    []string         `toon:"tags"`
+	Users   []schemaUser     `toon:"users"`
+	At      time.Time        `toon:"at"`
+	Day     time.Time        `toon:"day" format:"2006-01-02"`
+	Elapsed time.Duration    `toon:"elapsed"`
+	Digest  []byte           `toon:"digest" format:"hex"`
+	Scores  map[string]int   `toon:"scores"`
+	Tree    schemaNode       `toon:"tree"`
+	Teams   []schemaTeam     `toon:"teams"`
+	Extra   any              `toon:"extra"`
+	Matrix  [][]float64      `toon:"matrix"`
+	Ignored map[string]*bool `json:"ignored"`
+}
+
+// schemaTree and schemaList are maps and slices nested in themselves.
+type (
+	schemaTree map[string]schemaTree
+	schemaList []schemaList
+)
+
+type schemaTeam struct {
+	Name    string       `toon:"name"`
+	Members []schemaUser `toon:"members"`
+}
+
+func TestSchema(t *testing.T) {
+	t.Run("struct", func(t *testing.T) {
+		got, err := goon.SchemaOf[schemaReply]()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `answer : <string>  # required
+email : <string>  # optional
+tags[N]: <string>,...
+users[N]{name,age,size}:  # name required, age default: 18
+  <string>,<integer>,<number>
+at : <time RFC 3339>
+day : <time 2006-01-02>
+elapsed : <duration like 1h30m0s>
+digest : <hex>
+scores :
+  <key> : <integer>
+tree :
+  name : <string>
+  children[N]: <schemaNode>,...
+teams[N]:
+  - name : <string>
+    members[N]{name,age,size}:  # name required, age default: 18
+      <string>,<integer>,<number>
+extra : <any>
+matrix[N]:
+  - [N]: <number>,...`
+		if string(got) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("keys match Marshal", func(t *testing.T) {
+		schema, err := goon.SchemaOf[schemaUser]()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := goon.Marshal(schemaUser{Name: "Ada", Age: 36, Size: 1.7})
+		if err != nil {
+			t.Fatal(err)
+		}
+		key := func(line string) string { return strings.Fields(line)[0] }
+		schemaLines, dataLines := strings.Split(string(schema), "\n"), strings.Split(string(data), "\n")
+		if len(schemaLines) != len(dataLines) {
+			t.Fatalf("schema:\n%s\ndocument:\n%s", schema, data)
+		}
+		for i := range schemaLines {
+			if key(schemaLines[i]) != key(dataLines[i]) {
+				t.Errorf("line %d: schema %q, document %q", i+1, schemaLines[i], dataLines[i])
+			}
+		}
+	})
+
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{"root table", []schemaUser{}, "[N]{name,age,size}:  # name required, age default: 18\n  <string>,<integer>,<number>"},
+		{"root list", []any{}, "[N]: <any>,..."},
+		{"root map", map[string][]int{}, "<key>[N]: <integer>,..."},
+		{"pointer", &schemaNode{}, "name : <string>\nchildren[N]: <schemaNode>,..."},
+		{"primitive", 3, "<integer>"},
+		{"time", time.Time{}, "<time RFC 3339>"},
+		{"recursive map", schemaTree{}, "<key> : <schemaTree>"},
+		{"recursive slice", schemaList{}, "[N]: <schemaList>,..."},
+		{"recursive map in a struct", struct {
+			Tree schemaTree `toon:"tree"`
+		}{}, "tree :\n  <key> : <schemaTree>"},
+		{"recursive slice in a map", map[string]*schemaList{}, "<key>[N]: <schemaList>,..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := goon.Schema(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		if _, err := goon.Schema(nil); err == nil {
+			t.Error("Schema(nil) succeeded")
+		}
+		_, err := goon.SchemaOf[struct {
+			C chan int `toon:"c"`
+		}]()
+		if err == nil || err.Error() != "goon: unsupported type: chan int" {
+			t.Errorf("err = %v", err)
+		}
+	})
+}
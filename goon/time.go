@@ -0,0 +1,127 @@
+package goon
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Time formats accepted by Encoder.SetTimeFormat, Decoder.SetTimeFormat and
+// the `format` struct tag in addition to time layouts. They represent a
+// time.Time as an integer count of seconds, milliseconds or nanoseconds
+// since the Unix epoch.
+const (
+	TimeFormatUnix      = "unix"
+	TimeFormatUnixMilli = "unixmilli"
+	TimeFormatUnixNano  = "unixnano"
+)
+
+var (
+	timeType     = reflect.TypeFor[time.Time]()
+	durationType = reflect.TypeFor[time.Duration]()
+)
+
+// isTimeType reports whether t is encoded as a scalar by marshalTime.
+func isTimeType(t reflect.Type) bool {
+	return t == timeType || t == durationType
+}
+
+// marshalTime formats v when it holds a time.Time or a time.Duration and
+// reports whether it did. Durations are written as Go duration strings; times
+// use format, falling back to the encoder's time format and then RFC 3339.
+func (es *encodeState) marshalTime(v reflect.Value, format string) (string, bool) {
+	if !v.IsValid() {
+		return "", false
+	}
+
+	switch v.Type() {
+	case timeType:
+		if format == "" {
+			format = es.timeFormat
+		}
+		return formatTime(v.Interface().(time.Time), format), true
+	case durationType:
+		return formatString(time.Duration(v.Int()).String()), true
+	}
+	return "", false
+}
+
+func formatTime(t time.Time, format string) string {
+	switch format {
+	case TimeFormatUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case TimeFormatUnixMilli:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case TimeFormatUnixNano:
+		return strconv.FormatInt(t.UnixNano(), 10)
+	case "":
+		format = time.RFC3339Nano
+	}
+	return formatString(t.Format(format))
+}
+
+// unmarshalTime stores the decoded scalar v into dst when dst is a time.Time
+// or a time.Duration and reports whether it was one of them. A null value
+// leaves dst untouched.
+func (ds *decodeState) unmarshalTime(dst reflect.Value, v reflect.Value, format string) (bool, error) {
+	if !isTimeType(dst.Type()) {
+		return false, nil
+	}
+	if !v.IsValid() {
+		return true, nil
+	}
+
+	if dst.Type() == durationType {
+		s, ok := v.Interface().(string)
+		if !ok {
+			return true, fmt.Errorf("goon: trying to assign %s to time.Duration", v.Kind())
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return true, fmt.Errorf("goon: invalid duration %q: %w", s, err)
+		}
+		dst.SetInt(int64(d))
+		return true, nil
+	}
+
+	if format == "" {
+		format = ds.timeFormat
+	}
+	t, err := parseTime(v, format)
+	if err != nil {
+		return true, err
+	}
+	dst.Set(reflect.ValueOf(t))
+	return true, nil
+}
+
+func parseTime(v reflect.Value, format string) (time.Time, error) {
+	switch format {
+	case TimeFormatUnix, TimeFormatUnixMilli, TimeFormatUnixNano:
+		n, ok := v.Interface().(int)
+		if !ok {
+			return time.Time{}, fmt.Errorf("goon: trying to assign %s to %s time", v.Kind(), format)
+		}
+		switch format {
+		case TimeFormatUnix:
+			return time.Unix(int64(n), 0), nil
+		case TimeFormatUnixMilli:
+			return time.UnixMilli(int64(n)), nil
+		default:
+			return time.Unix(0, int64(n)), nil
+		}
+	case "":
+		format = time.RFC3339Nano
+	}
+
+	s, ok := v.Interface().(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("goon: trying to assign %s to time.Time", v.Kind())
+	}
+	t, err := time.Parse(format, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("goon: invalid time %q: %w", s, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,104 @@
+package goon_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/roboogg133/goon/goon"
+)
+
+type Event struct {
+	Name    string        `toon:"name"`
+	At      time.Time     `toon:"at"`
+	Day     time.Time     `toon:"day" format:"2006-01-02"`
+	Elapsed time.Duration `toon:"elapsed"`
+}
+
+type Timeline struct {
+	Created time.Time `toon:"created" format:"unix"`
+	Events  []Event   `toon:"events"`
+}
+
+func TestTime(t *testing.T) {
+
+	at := time.Date(2025, 11, 29, 10, 30, 0, 500, time.UTC)
+	day := time.Date(2025, 11, 29, 0, 0, 0, 0, time.UTC)
+
+	timeline := Timeline{
+		Created: time.Unix(1764412200, 0),
+		Events: []Event{
+			{Name: "launch", At: at, Day: day, Elapsed: 90 * time.Minute},
+			{Name: "landing", At: at.Add(time.Hour), Day: day, Elapsed: 1500 * time.Millisecond},
+		},
+	}
+
+	t.Run("marshal", func(t *testing.T) {
+		a, err := goon.Marshal(timeline)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := `created : 1764412200
+events[2]{name,at,day,elapsed}:
+  launch,"2025-11-29T10:30:00.0000005Z","2025-11-29","1h30m0s"
+  landing,"2025-11-29T11:30:00.0000005Z","2025-11-29","1.5s"`
+		if string(a) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", a, want)
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		a, err := goon.Marshal(timeline)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got Timeline
+		if err := goon.Unmarshal(a, &got); err != nil {
+			t.Fatal(err)
+		}
+
+		if !got.Created.Equal(timeline.Created) {
+			t.Errorf("created: got %v, want %v", got.Created, timeline.Created)
+		}
+		if len(got.Events) != len(timeline.Events) {
+			t.Fatalf("got %d events, want %d", len(got.Events), len(timeline.Events))
+		}
+		for i, e := range got.Events {
+			want := timeline.Events[i]
+			if e.Name != want.Name || !e.At.Equal(want.At) || !e.Day.Equal(want.Day) || e.Elapsed != want.Elapsed {
+				t.Errorf("event %d: got %+v, want %+v", i, e, want)
+			}
+		}
+	})
+
+	t.Run("encoder option", func(t *testing.T) {
+		var buf bytes.Buffer
+		enc := goon.NewEncoder(&buf)
+		enc.SetTimeFormat(goon.TimeFormatUnixMilli)
+
+		v := struct {
+			At time.Time `toon:"at"`
+		}{At: at}
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != "at : 1764412200000\n" {
+			t.Errorf("got %q", buf.String())
+		}
+
+		var got struct {
+			At time.Time `toon:"at"`
+		}
+		dec := goon.NewDecoder(&buf)
+		dec.SetTimeFormat(goon.TimeFormatUnixMilli)
+		if err := dec.Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if !got.At.Equal(at.Truncate(time.Millisecond)) {
+			t.Errorf("got %v", got.At)
+		}
+	})
+
+}
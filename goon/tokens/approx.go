@@ -0,0 +1,220 @@
+package tokens
+
+import (
+	_ "embed"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed vocab.txt
+var vocabFile string
+
+// approximate is the Tokenizer returned by Approximate.
+type approximate struct {
+	vocab  map[string]bool
+	maxLen int
+}
+
+var approximateOnce = sync.OnceValue(func() *approximate {
+	t := &approximate{vocab: make(map[string]bool)}
+	for line := range strings.Lines(vocabFile) {
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		tok, err := strconv.Unquote(line)
+		if err != nil {
+			panic("tokens: invalid vocabulary entry " + line)
+		}
+		t.vocab[tok] = true
+		t.maxLen = max(t.maxLen, len(tok))
+	}
+	return t
+})
+
+// Approximate returns a tokenizer approximating the BPE tokenizers of
+// GPT-style models such as cl100k_base, without their vocabulary of a
+// hundred thousand tokens.
+//
+// Like them, it first splits the text into words with their leading space,
+// groups of up to three digits, runs of punctuation and runs of spaces. Each
+// of these pieces is then split into the longest tokens of a small embedded
+// vocabulary of common words, subwords and punctuation sequences; letters it
+// doesn't cover cost a token per three bytes, and other characters a token
+// each. Counts are typically within ten to twenty percent of the exact ones,
+// which is enough to compare formats but not to enforce a context limit.
+func Approximate() Tokenizer {
+	return approximateOnce()
+}
+
+func (t *approximate) Count(text string) int {
+	n := 0
+	for len(text) > 0 {
+		i := splitPiece(text)
+		n += t.countPiece(text[:i])
+		text = text[i:]
+	}
+	return n
+}
+
+// splitPiece returns the length of the first piece of text, following the
+// pre-tokenization rules of cl100k_base.
+func splitPiece(text string) int {
+	r, size := utf8.DecodeRuneInString(text)
+
+	// Contractions.
+	if r == '\'' {
+		rest := strings.ToLower(text[1:min(len(text), 3)])
+		for _, c := range []string{"ll", "ve", "re", "s", "d", "m", "t"} {
+			if strings.HasPrefix(rest, c) {
+				return 1 + len(c)
+			}
+		}
+	}
+
+	// A word, with at most one leading character that isn't a letter, a
+	// digit or a line break.
+	i := 0
+	if !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\n' && r != '\r' {
+		i = size
+	}
+	if j := letters(text[i:]); j > 0 {
+		return i + j
+	}
+
+	if unicode.IsNumber(r) {
+		i = size
+		for digits := 1; digits < 3 && i < len(text); digits++ {
+			r, size := utf8.DecodeRuneInString(text[i:])
+			if !unicode.IsNumber(r) {
+				break
+			}
+			i += size
+		}
+		return i
+	}
+
+	// Punctuation, with at most one leading space and the line breaks
+	// following it.
+	i = 0
+	if r == ' ' {
+		i = 1
+	}
+	if j := punctuation(text[i:]); j > 0 {
+		i += j
+		for i < len(text) && (text[i] == '\n' || text[i] == '\r') {
+			i++
+		}
+		return i
+	}
+
+	// Spaces, up to their last line break, or leaving out the last space
+	// before a word.
+	i = 0
+	lastBreak := 0
+	for i < len(text) {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		i += size
+		if r == '\n' || r == '\r' {
+			lastBreak = i
+		}
+	}
+	switch {
+	case lastBreak > 0:
+		return lastBreak
+	case i < len(text) && i > size:
+		_, last := utf8.DecodeLastRuneInString(text[:i])
+		return i - last
+	case i > 0:
+		return i
+	}
+	return size
+}
+
+// letters returns the length of the run of letters text starts with.
+func letters(text string) int {
+	for i, r := range text {
+		if !unicode.IsLetter(r) {
+			return i
+		}
+	}
+	return len(text)
+}
+
+// punctuation returns the length of the run of characters that are neither
+// spaces, letters nor digits text starts with.
+func punctuation(text string) int {
+	for i, r := range text {
+		if unicode.IsSpace(r) || unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return i
+		}
+	}
+	return len(text)
+}
+
+// countPiece returns the number of tokens of a piece of text.
+func (t *approximate) countPiece(piece string) int {
+	if t.known(piece) {
+		return 1
+	}
+	r, _ := utf8.DecodeRuneInString(piece)
+	switch {
+	case unicode.IsNumber(r):
+		return 1
+	case unicode.IsSpace(r):
+		// Runs of spaces and line breaks are single tokens up to a length.
+		return (len(piece) + 15) / 16
+	}
+
+	n := 0
+	for len(piece) > 0 {
+		if i := t.longest(piece); i > 0 {
+			piece = piece[i:]
+		} else if r, size := utf8.DecodeRuneInString(piece); r < utf8.RuneSelf && unicode.IsLetter(r) {
+			// Uncommon words are split into pieces of about three letters.
+			i = 1
+			for i < 3 && i < len(piece) && piece[i] < utf8.RuneSelf && unicode.IsLetter(rune(piece[i])) {
+				i++
+			}
+			piece = piece[i:]
+		} else {
+			piece = piece[size:]
+		}
+		n++
+	}
+	return n
+}
+
+// known reports whether piece is a token of the vocabulary, ignoring the
+// case of its first letter.
+func (t *approximate) known(piece string) bool {
+	if t.vocab[piece] {
+		return true
+	}
+	i := 0
+	if piece != "" && piece[0] == ' ' {
+		i = 1
+	}
+	r, size := utf8.DecodeRuneInString(piece[i:])
+	if !unicode.IsUpper(r) {
+		return false
+	}
+	return t.vocab[piece[:i]+string(unicode.ToLower(r))+piece[i+size:]]
+}
+
+// longest returns the length of the longest token of at least two bytes
+// piece starts with, or 0.
+func (t *approximate) longest(piece string) int {
+	for i := min(len(piece), t.maxLen); i >= 2; i-- {
+		if t.known(piece[:i]) {
+			return i
+		}
+	}
+	return 0
+}
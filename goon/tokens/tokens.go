@@ -0,0 +1,101 @@
+// Package tokens counts the tokens a document costs in a language model
+// prompt, to compare the cost of a value written as TOON and as JSON.
+//
+// The bundled tokenizer, Approximate, runs offline and approximates the BPE
+// tokenizers of GPT-style models. Any other tokenizer can be plugged in by
+// implementing Tokenizer, or with Func:
+//
+//	exact := tokens.Func(func(text string) int {
+//		return len(enc.Encode(text, nil, nil))
+//	})
+//	report, err := tokens.CompareWith(exact, v)
+package tokens
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/roboogg133/goon/goon"
+)
+
+// A Tokenizer counts the tokens of a text.
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// Func adapts a function to the Tokenizer interface.
+type Func func(text string) int
+
+// Count returns f(text).
+func (f Func) Count(text string) int {
+	return f(text)
+}
+
+// A Size is the size of a document in bytes and tokens.
+type Size struct {
+	Bytes  int
+	Tokens int
+}
+
+// A Report compares the size of a value written in different formats.
+type Report struct {
+	// TOON is the value as written by goon.Marshal.
+	TOON Size
+	// JSON is the value as compact JSON, and PrettyJSON as JSON indented
+	// with two spaces, with the same keys as TOON.
+	JSON       Size
+	PrettyJSON Size
+}
+
+// Savings returns the fraction of the tokens of compact JSON saved by
+// writing the value as TOON, negative when TOON costs more.
+func (r Report) Savings() float64 {
+	if r.JSON.Tokens == 0 {
+		return 0
+	}
+	return float64(r.JSON.Tokens-r.TOON.Tokens) / float64(r.JSON.Tokens)
+}
+
+// Compare returns the size of v written as TOON, compact JSON and pretty
+// JSON, counting tokens with the Approximate tokenizer. A json.RawMessage is
+// taken as the JSON document it holds.
+func Compare(v any) (Report, error) {
+	return CompareWith(Approximate(), v)
+}
+
+// CompareWith is like Compare but counts tokens with t.
+func CompareWith(t Tokenizer, v any) (Report, error) {
+	var toon []byte
+	var err error
+	if raw, ok := v.(json.RawMessage); ok {
+		toon, err = goon.FromJSON(raw)
+	} else {
+		toon, err = goon.Marshal(v)
+	}
+	if err != nil {
+		return Report{}, err
+	}
+	return CompareTOON(t, toon)
+}
+
+// CompareTOON is like CompareWith for a value already written as the TOON
+// document data.
+func CompareTOON(t Tokenizer, data []byte) (Report, error) {
+	compact, err := goon.ToJSON(data)
+	if err != nil {
+		return Report{}, err
+	}
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, compact, "", "  "); err != nil {
+		return Report{}, err
+	}
+	return Report{
+		TOON:       sizeOf(t, bytes.TrimSuffix(data, []byte("\n"))),
+		JSON:       sizeOf(t, compact),
+		PrettyJSON: sizeOf(t, pretty.Bytes()),
+	}, nil
+}
+
+func sizeOf(t Tokenizer, data []byte) Size {
+	return Size{Bytes: len(data), Tokens: t.Count(string(data))}
+}
@@ -0,0 +1,119 @@
+package tokens_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/roboogg133/goon/goon/tokens"
+)
+
+func TestApproximate(t *testing.T) {
+	tok := tokens.Approximate()
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello world", 2},
+		{"The quick brown fox jumps over the lazy dog.", 10},
+		{"1234567", 3},
+		{`{"id":1,"name":"Ada"}`, 9},
+		{"        ", 1},
+		{"don't", 2},
+	}
+	for _, tt := range tests {
+		if got := tok.Count(tt.text); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+
+	t.Run("unknown words", func(t *testing.T) {
+		// Words missing from the vocabulary cost more than one token, but
+		// less than one per letter.
+		for _, word := range []string{"Lovelace", "zyxwvutsrq", "tokenization"} {
+			if n := tok.Count(word); n < 2 || n >= len(word) {
+				t.Errorf("Count(%q) = %d", word, n)
+			}
+		}
+	})
+
+	t.Run("additive", func(t *testing.T) {
+		line := "name : Ada Lovelace\n"
+		if got, want := tok.Count(strings.Repeat(line, 10)), 10*tok.Count(line); got != want {
+			t.Errorf("Count of 10 lines = %d, want %d", got, want)
+		}
+	})
+}
+
+type person struct {
+	ID    int    `toon:"id"`
+	Name  string `toon:"name"`
+	Email string `toon:"email"`
+	Admin bool   `toon:"admin"`
+}
+
+func TestCompare(t *testing.T) {
+	people := []person{
+		{1, "Ada Lovelace", "ada@example.com", true},
+		{2, "Alan Turing", "alan@example.com", false},
+		{3, "Grace Hopper", "grace@example.com", true},
+		{4, "Linus Torvalds", "linus@example.com", false},
+	}
+
+	t.Run("tabular", func(t *testing.T) {
+		r, err := tokens.Compare(map[string]any{"people": people})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !(r.TOON.Tokens < r.JSON.Tokens && r.JSON.Tokens < r.PrettyJSON.Tokens) {
+			t.Errorf("tokens: TOON %d, JSON %d, pretty JSON %d", r.TOON.Tokens, r.JSON.Tokens, r.PrettyJSON.Tokens)
+		}
+		if !(r.TOON.Bytes < r.JSON.Bytes && r.JSON.Bytes < r.PrettyJSON.Bytes) {
+			t.Errorf("bytes: TOON %d, JSON %d, pretty JSON %d", r.TOON.Bytes, r.JSON.Bytes, r.PrettyJSON.Bytes)
+		}
+		if s := r.Savings(); s <= 0 || s >= 1 {
+			t.Errorf("Savings() = %v", s)
+		}
+	})
+
+	t.Run("raw JSON", func(t *testing.T) {
+		data := json.RawMessage(`{"id":1,"name":"Ada Lovelace","email":"ada@example.com","admin":true}`)
+		r, err := tokens.Compare(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.JSON.Bytes != len(data) {
+			t.Errorf("JSON bytes = %d, want %d", r.JSON.Bytes, len(data))
+		}
+		want, err := tokens.Compare(people[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r != want {
+			t.Errorf("Compare(raw) = %+v, want %+v", r, want)
+		}
+	})
+
+	t.Run("tokenizer", func(t *testing.T) {
+		bytes := tokens.Func(func(text string) int { return len(text) })
+		r, err := tokens.CompareWith(bytes, people)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range []tokens.Size{r.TOON, r.JSON, r.PrettyJSON} {
+			if s.Tokens != s.Bytes {
+				t.Errorf("size %+v counted with len", s)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := tokens.Compare(json.RawMessage(`{"a":`)); err == nil {
+			t.Error("Compare of invalid JSON succeeded")
+		}
+		if _, err := tokens.CompareTOON(tokens.Approximate(), []byte("a[2]: 1")); err == nil {
+			t.Error("CompareTOON of invalid TOON succeeded")
+		}
+	})
+}
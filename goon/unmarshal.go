@@ -1,19 +1,133 @@
 package goon
 
 import (
-	"bufio"
-	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
-	"regexp"
-	"strconv"
 	"strings"
 )
 
 type posStruct struct {
 	Name string
 	Pos  int
+	// Format is the `format` tag of the field, used for time.Time values.
+	Format string
+	// Required is set by the `required` tag option.
+	Required bool
+	// Default is the `default` tag of the field, decoded when the key is
+	// missing. HasDefault reports whether the tag is present.
+	Default    string
+	HasDefault bool
+	// Aliases are the other keys accepted for the field, set by the
+	// `alias=a|b` tag option.
+	Aliases []string
+}
+
+// A Decoder reads and decodes TOON documents from an input stream.
+type Decoder struct {
+	r    io.Reader
+	opts decOpts
+}
+
+// decOpts holds the options configured on a Decoder.
+type decOpts struct {
+	// timeFormat is the layout, or one of the TimeFormatUnix modes, used for
+	// time.Time fields without a `format` tag. Empty means time.RFC3339Nano.
+	timeFormat string
+	// binaryEncoding is the encoding of []byte and [N]byte fields without a
+	// `format` tag. Empty means BinaryBase64.
+	binaryEncoding string
+	limits         Limits
+
+	disallowUnknownFields bool
+	caseInsensitive       bool
+	aliasReporter         func(path, key string)
+	strictIndentation     bool
+}
+
+// decodeState carries the options of a single Unmarshal or Decode call, and
+// collects the keys that don't match the target structs.
+type decodeState struct {
+	decOpts
+
+	unknown []string
+	missing []string
+
+	// indent is the number of spaces per nesting level of the document,
+	// inferred from its first indented line.
+	indent int
+}
+
+// object is a struct or map being decoded, along with the indentation depth
+// of its keys and its path from the root of the document.
+type object struct {
+	value  reflect.Value
+	fields map[string]posStruct
+	depth  int
+	path   string
+	seen   map[string]bool
+	// commit stores value into its parent once all of its keys are decoded,
+	// for parents such as maps whose elements are not addressable.
+	commit func()
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// SetTimeFormat sets how time.Time fields without a `format` tag are decoded.
+// It accepts the same values as Encoder.SetTimeFormat.
+func (dec *Decoder) SetTimeFormat(format string) {
+	dec.opts.timeFormat = format
+}
+
+// SetBinaryEncoding sets how []byte and [N]byte fields without a `format` tag
+// are decoded. It accepts the same values as Encoder.SetBinaryEncoding.
+func (dec *Decoder) SetBinaryEncoding(encoding string) {
+	dec.opts.binaryEncoding = encoding
+}
+
+// DisallowUnknownFields causes the Decoder to return an error when the
+// document contains keys that do not match any field of the destination
+// struct. The error is a *FieldError listing every unknown key.
+func (dec *Decoder) DisallowUnknownFields() {
+	dec.opts.disallowUnknownFields = true
+}
+
+// MatchCaseInsensitive causes the Decoder to match keys to struct fields and
+// their aliases ignoring case, like encoding/json does, when no field matches
+// a key exactly.
+func (dec *Decoder) MatchCaseInsensitive() {
+	dec.opts.caseInsensitive = true
+}
+
+// SetAliasReporter registers a function that is called for every key that
+// was matched to a struct field through an alias or ignoring case, with the
+// path of the field and the key as it appears in the document.
+func (dec *Decoder) SetAliasReporter(report func(path, key string)) {
+	dec.opts.aliasReporter = report
+}
+
+// StrictIndentation causes the Decoder to return a *SyntaxError for lines
+// indented with tabs or by a number of spaces that is not a multiple of the
+// indentation of the document. Otherwise tabs are not counted as indentation
+// and partial levels are rounded down.
+func (dec *Decoder) StrictIndentation() {
+	dec.opts.strictIndentation = true
+}
+
+// Decode reads the TOON document from its input until EOF and stores the
+// result in the value pointed to by v.
+func (dec *Decoder) Decode(v any) error {
+	ds := &decodeState{decOpts: dec.opts}
+	data, err := ds.readDocument(dec.r)
+	if err != nil {
+		return err
+	}
+
+	return ds.unmarshal(data, v)
 }
 
 const IndentationRune = ' '
@@ -31,7 +145,42 @@ func calcIndent(s string) int {
 	return total
 }
 
+// detectIndent returns the indentation of the first indented line of data,
+// which is the indentation unit of the document since that line is nested
+// one level below the top-level keys. Documents without indented lines use
+// Indentation.
+func detectIndent(data []byte) int {
+	for line := range strings.Lines(string(data)) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if n := calcIndent(line); n > 0 {
+			return n
+		}
+	}
+	return len(Indentation)
+}
+
+// depthOf returns the nesting level of the line text, found at line.
+func (ds *decodeState) depthOf(text string, line int) (int, error) {
+	n := calcIndent(text)
+	if ds.strictIndentation {
+		if rest := text[n:]; strings.HasPrefix(rest, "\t") {
+			return 0, &SyntaxError{msg: "tabs are not allowed in indentation", Line: line, Column: n + 1}
+		}
+		if n%ds.indent != 0 {
+			return 0, &SyntaxError{msg: fmt.Sprintf("indentation of %d spaces is not a multiple of %d", n, ds.indent), Line: line, Column: n + 1}
+		}
+	}
+	return n / ds.indent, nil
+}
+
 func Unmarshal(data []byte, v any) error {
+	ds := &decodeState{}
+	return ds.unmarshal(data, v)
+}
+
+func (ds *decodeState) unmarshal(data []byte, v any) error {
 
 	rv := reflect.ValueOf(v)
 	kind := rv.Type().Kind()
@@ -39,292 +188,543 @@ func Unmarshal(data []byte, v any) error {
 	if kind != reflect.Pointer || rv.IsNil() {
 		return errors.New("goon: v must be a non-nil pointer")
 	}
-
-	rv = rv.Elem()
-	kind = rv.Kind()
-
-	structMap := make(map[string]posStruct)
-
-	switch kind {
-	case reflect.Struct:
-		for i := 0; i < rv.NumField(); i++ {
-			field := rv.Type().Field(i)
-			structMap[field.Tag.Get("toon")] = posStruct{
-				Name: field.Tag.Get("toon"),
-				Pos:  i,
-			}
+	if u, ok := v.(ObjectUnmarshaler); ok {
+		ds.indent = detectIndent(data)
+		r := ds.objectReader(newLineScanner(data, ds.limits.MaxLineLength), 0, "")
+		if err := u.UnmarshalTOONObject(r); err != nil {
+			return err
 		}
+		return r.Close()
+	}
+	if u, ok := v.(Unmarshaler); ok {
+		return u.UnmarshalTOON(data)
+	}
+
+	root, err := ds.newObject(rv.Elem(), 0, "")
+	if err != nil {
+		return err
+	}
+	ds.indent = detectIndent(data)
+	if err := ds.decodeObject(root, newLineScanner(data, ds.limits.MaxLineLength)); err != nil {
+		return err
 	}
-	reader := bytes.NewReader(data)
-	scanner := bufio.NewScanner(reader)
+	if len(ds.unknown) > 0 || len(ds.missing) > 0 {
+		return &FieldError{Unknown: ds.unknown, Missing: ds.missing}
+	}
+	return nil
+}
 
-	r, _ := regexp.Compile(`^(.*?)\[\s*([1-9]\d*)\s*\]`)
-	csvl, _ := regexp.Compile(`^(.*?)\[\s*([1-9]\d*)([|\t]?)\s*\]\{([^}]+)\}`)
+// decodeObject decodes the keys of root and of the objects nested below it,
+// up to the first line that is not nested at the depth of root or below.
+func (ds *decodeState) decodeObject(root *object, scanner *lineScanner) error {
+	stack := []*object{root}
+
+	// keyCounts holds the number of keys seen so far in the object open at
+	// each indentation level.
+	var keyCounts []int
 
 	for scanner.Scan() {
 		text := scanner.Text()
 
 		if strings.HasPrefix(text, string(rune(3))) {
-			return nil
-		} else if text == "" {
+			scanner.Unscan()
+			break
+		} else if strings.TrimSpace(text) == "" {
 			continue
 		}
 
-		strDoubleDot := strings.SplitN(text, ":", 2)
+		depth, err := ds.depthOf(text, scanner.line)
+		if err != nil {
+			return err
+		}
+		if depth < root.depth {
+			scanner.Unscan()
+			break
+		}
+		if max := ds.limits.MaxDepth; max > 0 && depth+1 > max {
+			return &LimitError{Limit: "depth", Max: max, Line: scanner.line}
+		}
+		if depth < len(keyCounts) {
+			keyCounts = keyCounts[:depth+1]
+		} else {
+			keyCounts = append(keyCounts, make([]int, depth+1-len(keyCounts))...)
+		}
+		keyCounts[depth]++
+		if max := ds.limits.MaxKeys; max > 0 && keyCounts[depth] > max {
+			return &LimitError{Limit: "keys per object", Max: max, Line: scanner.line}
+		}
 
-		// if is true is a csv like list
-		if csvl.MatchString(strings.TrimSpace(strDoubleDot[0])) && strings.TrimSpace(strDoubleDot[1]) == "" {
-			matches := csvl.FindStringSubmatch(strings.TrimSpace(strDoubleDot[0]))
-			if matches[3] == "" {
-				matches[3] = ","
+		for len(stack) > 1 && depth < stack[len(stack)-1].depth {
+			if err := ds.closeObject(stack[len(stack)-1]); err != nil {
+				return err
 			}
-			length, err := strconv.Atoi(matches[2])
+			stack = stack[:len(stack)-1]
+		}
+		obj := stack[len(stack)-1]
+		rv, kind := obj.value, obj.value.Kind()
+
+		strDoubleDot, err := splitKeyValue(text)
+		if err != nil {
+			return err
+		}
+
+		header, isArray, err := parseArrayHeader(strings.TrimSpace(strDoubleDot[0]), scanner.line)
+		if err != nil {
+			return err
+		}
+		value := strings.TrimSpace(strDoubleDot[1])
+
+		if isArray {
+			name, err := parseKey(header.key)
 			if err != nil {
 				return err
 			}
-			sliceObject, err := csvLike(scanner, length, strings.Split(matches[4], matches[3]), matches[3])
+			dst, path, format, commit, err := ds.member(obj, name)
 			if err != nil {
 				return err
 			}
-
-			switch kind {
-			case reflect.Struct:
-				a, exists := structMap[matches[1]]
-				if !exists {
-					continue
+			if err := ds.decodeArray(dst, scanner, depth, header, value, path, format); err != nil {
+				return err
+			}
+			if commit != nil {
+				commit()
+			}
+			continue
+		}
+		name, err := parseKey(strDoubleDot[0])
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			if dst, commit, ok := ds.unmarshalerFor(obj, name); ok {
+				path := joinPath(obj.path, name)
+				if kind == reflect.Struct {
+					a, _ := ds.field(obj, name)
+					path = joinPath(obj.path, a.Name)
 				}
-				field := rv.Field(a.Pos)
-
-				if field.Kind() != reflect.Slice {
-					return fmt.Errorf("invalid type can only unmarshal a csv like object on slices")
+				if err := ds.callUnmarshaler(dst, scanner, depth+1, path); err != nil {
+					return err
 				}
-
-				sliceType := field.Type().Elem()
-				switch sliceType.Kind() {
-				case reflect.Struct:
-
-					elemType := field.Type().Elem()
-
-					innerMap := make(map[string]posStruct)
-
-					for i := 0; i < elemType.NumField(); i++ {
-						var a posStruct
-						a.Name = elemType.Field(i).Tag.Get("toon")
-
-						if a.Name == "" {
-							continue
-						}
-						a.Pos = i
-						innerMap[a.Name] = a
-					}
-
-					newSlice := reflect.MakeSlice(field.Type(), 0, len(sliceObject))
-
-					for _, structs := range sliceObject {
-						newElement := reflect.New(elemType).Elem()
-						for j, v := range structs {
-							b, exists := innerMap[j]
-							if !exists {
-								continue
-							}
-							if !v.IsValid() {
-								continue
-							}
-							newElement.Field(b.Pos).Set(v)
-						}
-						newSlice = reflect.Append(newSlice, newElement)
-					}
-
-					field.Set(newSlice)
-				case reflect.Map:
-					var result []map[string]any
-
-					new := reflect.MakeSlice(reflect.TypeOf(result), len(sliceObject), len(sliceObject))
-
-					for i, v := range sliceObject {
-						temp := make(map[string]any)
-						for i, v2 := range v {
-							if !v2.IsValid() {
-								continue
-							}
-							temp[i] = v2.Interface()
-						}
-						result = append(result, temp)
-						new.Index(i).Set(reflect.ValueOf(temp))
-					}
-
-					field.Set(new)
+				if commit != nil {
+					commit()
 				}
 				continue
-			case reflect.Map:
-
-				var result []map[string]any
-
-				new := reflect.MakeSlice(reflect.TypeOf(result), len(sliceObject), len(sliceObject))
-
-				for i, v := range sliceObject {
-					temp := make(map[string]any)
-					for i, v2 := range v {
-						if !v2.IsValid() {
-							continue
-						}
-						temp[i] = v2.Interface()
-					}
-					result = append(result, temp)
-					new.Index(i).Set(reflect.ValueOf(temp))
-				}
-
-				rv.SetMapIndex(reflect.ValueOf(matches[1]), new)
 			}
-			continue
-		} else if r.MatchString(strings.TrimSpace(strDoubleDot[0])) && strings.TrimSpace(strDoubleDot[1]) == "" {
-			matches := r.FindStringSubmatch(strings.TrimSpace(strDoubleDot[0]))
-
-			listLenght, err := strconv.Atoi(matches[2])
-			if err != nil {
-				return fmt.Errorf("goon: error parsing mixed list -> %s ", err.Error())
-			}
-
-			slice, err := multipleLineList(scanner, listLenght)
+			child, err := ds.openObject(obj, name, depth+1)
 			if err != nil {
 				return err
 			}
-
-			switch kind {
-			case reflect.Struct:
-				a := structMap[strings.TrimSpace(strings.Split(strDoubleDot[0], "[")[0])]
-
-				kind := rv.Kind()
-
-				if kind == reflect.Pointer {
-					rv = rv.Elem()
-					kind = rv.Kind()
-				}
-
-				field := rv.Field(a.Pos)
-				fieldKind := field.Kind()
-
-				if fieldKind == reflect.Pointer {
-					field = field.Elem()
-					fieldKind = field.Kind()
-				}
-
-				if field.Kind() != slice.Kind() && fieldKind != reflect.Interface {
-					return fmt.Errorf("goon: trying to assign %s to %s", slice.Kind(), fieldKind)
-				}
-
-				field.Set(slice)
-			case reflect.Map:
-				rv.SetMapIndex(reflect.ValueOf(strings.TrimSpace(strings.Split(strDoubleDot[0], "[")[0])), slice)
-			}
+			stack = append(stack, child)
 			continue
 		}
-		posVal, err := recognizeType(strings.TrimSpace(strDoubleDot[1]))
+		posVal, err := recognizeType(value)
 		if err != nil {
 			return err
 		}
-		if !posVal.IsValid() {
-			continue
-		}
 		switch kind {
 		case reflect.Struct:
-			a := structMap[strings.TrimSpace(strings.Split(strDoubleDot[0], "[")[0])]
-			if err := signToStruct(rv, strings.TrimSpace(strDoubleDot[1]), a); err != nil {
+			a, exists := ds.field(obj, name)
+			if !exists {
+				continue
+			}
+			if err := ds.assign(rv.Field(a.Pos), posVal, a.Format); err != nil {
 				return err
 			}
 
 		case reflect.Map:
-			rv.SetMapIndex(reflect.ValueOf(strings.Split(strings.TrimSpace(strDoubleDot[0]), "[")[0]), posVal)
+			if err := ds.setMapIndex(rv, name, posVal); err != nil {
+				return err
+			}
 		}
 		continue
 
 	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
 
+	for i := len(stack) - 1; i >= 0; i-- {
+		if err := ds.closeObject(stack[i]); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func signToStruct(rv reflect.Value, rawValue string, a posStruct) error {
+// newObject prepares v, the destination of an object at the given depth and
+// path, for decoding its keys. Nil pointers are allocated, nil maps are made,
+// and an empty interface receives a new map[string]any.
+func (ds *decodeState) newObject(v reflect.Value, depth int, path string) (*object, error) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
 
-	posVal, err := recognizeType(rawValue)
-	if err != nil {
-		return err
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		m := reflect.ValueOf(make(map[string]any))
+		v.Set(m)
+		v = m
 	}
-	kind := rv.Kind()
 
-	if kind == reflect.Pointer {
-		rv = rv.Elem()
-		kind = rv.Kind()
+	obj := &object{value: v, depth: depth, path: path, seen: make(map[string]bool)}
+	switch v.Kind() {
+	case reflect.Struct:
+		obj.fields = fieldsOf(v.Type())
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+	default:
+		return nil, fmt.Errorf("goon: trying to assign object to %s", v.Type())
 	}
+	return obj, nil
+}
 
-	field := rv.Field(a.Pos)
-	fieldKind := field.Kind()
+// member returns dst, the destination of the key name of obj, along with
+// the path and `format` tag of the key. Keys that don't match any field are
+// decoded into a throwaway value. The elements of maps are not addressable,
+// so commit stores dst into a map once it is decoded.
+func (ds *decodeState) member(obj *object, name string) (dst reflect.Value, path, format string, commit func(), err error) {
+	if obj.value.Kind() == reflect.Struct {
+		a, exists := ds.field(obj, name)
+		if !exists {
+			return reflect.New(reflect.TypeFor[any]()).Elem(), joinPath(obj.path, name), "", nil, nil
+		}
+		return obj.value.Field(a.Pos), joinPath(obj.path, a.Name), a.Format, nil, nil
+	}
 
-	if fieldKind == reflect.Pointer {
-		field = field.Elem()
-		fieldKind = field.Kind()
+	m := obj.value
+	key, err := decodeMapKey(m.Type().Key(), name)
+	if err != nil {
+		return reflect.Value{}, "", "", nil, err
 	}
+	elem := reflect.New(m.Type().Elem()).Elem()
+	return elem, joinPath(obj.path, name), "", func() { m.SetMapIndex(key, elem) }, nil
+}
 
-	if field.Kind() != posVal.Kind() && fieldKind != reflect.Interface {
-		return fmt.Errorf("goon: trying to assign %s to %s", posVal.Kind(), fieldKind)
+// openObject returns the object for the nested key name of parent, whose
+// own keys are indented at depth. The keys of a nested object that doesn't
+// match any field are decoded into a throwaway map.
+func (ds *decodeState) openObject(parent *object, name string, depth int) (*object, error) {
+	dst, path, _, commit, err := ds.member(parent, name)
+	if err != nil {
+		return nil, err
 	}
+	obj, err := ds.newObject(dst, depth, path)
+	if err != nil {
+		return nil, err
+	}
+	obj.commit = commit
+	return obj, nil
+}
 
-	field.Set(posVal)
+// closeObject finishes decoding obj once all of its keys have been read,
+// applying the defaults of the missing fields.
+func (ds *decodeState) closeObject(obj *object) error {
+	seen := func(name string) bool { return obj.seen[name] }
+	for _, name := range missingFields(obj.fields, seen) {
+		ds.missing = append(ds.missing, joinPath(obj.path, name))
+	}
+	if err := ds.applyDefaults(obj.value, obj.fields, seen); err != nil {
+		return err
+	}
 
+	if obj.commit != nil {
+		obj.commit()
+	}
 	return nil
 }
 
-func multipleLineList(scanner *bufio.Scanner, listLength int) (reflect.Value, error) {
+// applyDefaults decodes the `default` tags of the fields of the struct v
+// whose keys were not seen.
+func (ds *decodeState) applyDefaults(v reflect.Value, fields map[string]posStruct, seen func(name string) bool) error {
+	for name, f := range fields {
+		if !f.HasDefault || seen(name) {
+			continue
+		}
+		if err := ds.decodeDefault(v.Field(f.Pos), name, f.Default, f.Format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	var elems []reflect.Value
+// decodeDefault decodes def, the `default` tag of the field name, into
+// field. Defaults of slices and arrays are inline lists such as `a,b`.
+func (ds *decodeState) decodeDefault(field reflect.Value, name, def, format string) error {
+	var value reflect.Value
+	var err error
+	if t := indirectType(field.Type()); (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && !isBytesType(t) {
+		value, err = recognizeList(def, ",")
+	} else {
+		value, err = recognizeType(def)
+	}
+	if err == nil {
+		err = ds.assign(field, value, format)
+	}
+	if err != nil {
+		return fmt.Errorf("goon: invalid default for %s: %w", name, err)
+	}
+	return nil
+}
 
-	for i := 0; i < listLength; i++ {
-		scanner.Scan()
-		trimmedLine := strings.TrimSpace(scanner.Text())
+// indirectType returns the type t points to, through any number of pointers.
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
 
-		if !strings.HasPrefix(trimmedLine, "-") {
-			i--
-			continue
+// field returns the struct field of obj for the key name, recording the
+// field as seen. Unknown keys are recorded when the decoder disallows them,
+// and keys matching a field through an alias or by case-insensitive matching
+// are passed to the alias reporter.
+func (ds *decodeState) field(obj *object, name string) (posStruct, bool) {
+	a, exists := lookupField(obj.fields, name, ds.caseInsensitive)
+	if !exists {
+		if ds.disallowUnknownFields {
+			ds.unknown = append(ds.unknown, joinPath(obj.path, name))
 		}
+		return posStruct{}, false
+	}
 
-		splited := strings.SplitN(trimmedLine, " ", 2)
+	obj.seen[a.Name] = true
+	if a.Name != name && ds.aliasReporter != nil {
+		ds.aliasReporter(joinPath(obj.path, a.Name), name)
+	}
+	return a, true
+}
+
+// unmarshalScalar stores the decoded scalar v into dst when dst has a type
+// that marshalScalar encodes as a scalar, reporting whether it did.
+func (ds *decodeState) unmarshalScalar(dst reflect.Value, v reflect.Value, format string) (bool, error) {
+	if ok, err := ds.unmarshalTime(dst, v, format); ok {
+		return true, err
+	}
+	return ds.unmarshalBytes(dst, v, format)
+}
 
-		value, err := recognizeType(strings.TrimSpace(splited[1]))
+// decodeArray decodes into dst the array whose header h is at depth,
+// followed on its line by value: a tabular array, a list or an inline array.
+// path is the path of the array and format the `format` tag of its field.
+func (ds *decodeState) decodeArray(dst reflect.Value, scanner *lineScanner, depth int, h arrayHeader, value, path, format string) error {
+	if err := ds.checkArrayLength(h.length, scanner.line); err != nil {
+		return err
+	}
+
+	switch {
+	case h.tabular && value == "":
+		fields, err := splitDelimited(h.fields, h.delim)
+		if err != nil {
+			return err
+		}
+		if max := ds.limits.MaxKeys; max > 0 && len(fields) > max {
+			return &LimitError{Limit: "keys per object", Max: max, Line: scanner.line}
+		}
+		for i, f := range fields {
+			if fields[i], err = parseKey(f); err != nil {
+				return err
+			}
+		}
+		rows, err := ds.csvLike(scanner, depth, h.length, fields, h.delim)
 		if err != nil {
-			return reflect.ValueOf(nil), nil
+			return err
 		}
+		return ds.assignTable(dst, fields, rows, path)
 
-		elems = append(elems, value)
+	case value == "" && h.length > 0:
+		return ds.decodeList(dst, scanner, depth, h.length, path, format)
 	}
 
-	sliceType := reflect.SliceOf(reflect.TypeFor[any]())
+	list, err := recognizeList(value, h.delim)
+	if err != nil {
+		return err
+	}
+	if list.Len() != h.length {
+		return lengthMismatch(scanner.line, h.length, list.Len())
+	}
+	return ds.assign(dst, list, format)
+}
+
+// decodeList decodes the length items of the list whose header is at depth
+// into dst, a slice, an array or an empty interface, which receives a []any.
+// The list ends early, which is an error, at the first line that is not
+// nested below its header.
+func (ds *decodeState) decodeList(dst reflect.Value, scanner *lineScanner, depth, length int, path, format string) error {
+	for dst.Kind() == reflect.Pointer {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
 
-	sliceValue := reflect.MakeSlice(sliceType, 0, len(elems))
-	for _, e := range elems {
-		sliceValue = reflect.Append(sliceValue, e)
+	var listType reflect.Type
+	switch {
+	case isBytesType(dst.Type()):
+	case dst.Kind() == reflect.Slice:
+		listType = dst.Type()
+	case dst.Kind() == reflect.Array:
+		listType = reflect.SliceOf(dst.Type().Elem())
+	case dst.Kind() == reflect.Interface && dst.NumMethod() == 0:
+		listType = reflect.TypeFor[[]any]()
+	}
+	if listType == nil {
+		return fmt.Errorf("goon: trying to assign list to %s", dst.Type())
 	}
 
-	return sliceValue, nil
+	list := reflect.MakeSlice(listType, 0, min(length, maxPreallocated))
+	for list.Len() < length {
+		if !scanner.Scan() {
+			return unexpectedEOF(scanner, length, list.Len())
+		}
+		trimmedLine := strings.TrimSpace(scanner.Text())
+		if trimmedLine == "" {
+			continue
+		}
+		d, err := ds.depthOf(scanner.Text(), scanner.line)
+		if err != nil {
+			return err
+		}
+		if d <= depth {
+			scanner.Unscan()
+			return unexpectedEnd(scanner.line, length, list.Len())
+		}
+
+		rest, ok := strings.CutPrefix(trimmedLine, "-")
+		if !ok || rest != "" && rest[0] != ' ' {
+			return &SyntaxError{msg: "expected a list item", Line: scanner.line}
+		}
+		list = reflect.Append(list, reflect.Zero(listType.Elem()))
+		if err := ds.decodeItem(list.Index(list.Len()-1), scanner, d, strings.TrimSpace(rest), path+"[]", format); err != nil {
+			return err
+		}
+	}
+
+	if dst.Kind() == reflect.Array {
+		if list.Len() != dst.Len() {
+			return fmt.Errorf("goon: trying to assign %d elements to %s", list.Len(), dst.Type())
+		}
+		reflect.Copy(dst, list)
+		return nil
+	}
+	dst.Set(list)
+	return nil
 }
 
-func csvLike(scanner *bufio.Scanner, listLength int, orderList []string, sep string) ([]map[string]reflect.Value, error) {
+// decodeItem decodes into dst the list item at depth whose line continues
+// with rest after the `- ` marker: a scalar, an array whose header has no
+// key, or an object whose first key continues the line of the marker and
+// whose other keys are nested one level below it.
+func (ds *decodeState) decodeItem(dst reflect.Value, scanner *lineScanner, depth int, rest, path, format string) error {
+	parts, err := splitKeyValue(rest)
+	switch {
+	case rest != "" && err != nil:
+		value, err := recognizeType(rest)
+		if err != nil {
+			return err
+		}
+		return ds.assign(dst, value, format)
+
+	case strings.HasPrefix(rest, "["):
+		h, ok, err := parseArrayHeader(strings.TrimSpace(parts[0]), scanner.line)
+		if err != nil {
+			return err
+		}
+		if !ok || h.key != "" {
+			return &SyntaxError{msg: "invalid array header", Line: scanner.line}
+		}
+		return ds.decodeArray(dst, scanner, depth, h, strings.TrimSpace(parts[1]), path, format)
+	}
+
+	// The first key of an object is read again as if it started at the
+	// depth of the others. A bare `-` marker is an empty object, as in
+	// ToJSON.
+	if rest != "" {
+		scanner.Replace(strings.Repeat(" ", (depth+1)*ds.indent) + rest)
+	}
+	if u, ok := unmarshalerOf(dst); ok {
+		return ds.callUnmarshaler(u, scanner, depth+1, path)
+	}
+	obj, err := ds.newObject(dst, depth+1, path)
+	if err != nil {
+		return err
+	}
+	return ds.decodeObject(obj, scanner)
+}
 
-	var maplists []map[string]reflect.Value
-	for _ = range listLength {
-		scanner.Scan()
+// maxPreallocated is the number of elements allocated up front for an array,
+// whatever its header declares, so that the memory spent on an array grows
+// with the elements actually read.
+const maxPreallocated = 1024
+
+// csvLike reads the listLength rows of a tabular array, whose header at
+// depth lists the fields orderList. The cells of every row are returned in
+// the order of the header. The array ends early, which is an error, at the
+// first line that is not nested below its header.
+func (ds *decodeState) csvLike(scanner *lineScanner, depth, listLength int, orderList []string, sep string) ([][]reflect.Value, error) {
+
+	rows := make([][]reflect.Value, 0, min(listLength, maxPreallocated))
+	var cells []reflect.Value
+	for len(rows) < listLength {
+		if !scanner.Scan() {
+			return nil, unexpectedEOF(scanner, listLength, len(rows))
+		}
 		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		d, err := ds.depthOf(scanner.Text(), scanner.line)
+		if err != nil {
+			return nil, err
+		}
+		if d <= depth {
+			scanner.Unscan()
+			return nil, unexpectedEnd(scanner.line, listLength, len(rows))
+		}
 
-		splited := strings.Split(text, sep)
+		splited, err := splitDelimited(text, sep)
+		if err != nil {
+			return nil, err
+		}
+		if len(splited) != len(orderList) {
+			return nil, &SyntaxError{msg: fmt.Sprintf("row has %d values but the header declares %d fields", len(splited), len(orderList)), Line: scanner.line}
+		}
 
-		maps := make(map[string]reflect.Value)
+		if len(cells) < len(orderList) {
+			cells = make([]reflect.Value, min(listLength-len(rows), maxPreallocated)*len(orderList))
+		}
+		row := cells[:len(orderList):len(orderList)]
+		cells = cells[len(orderList):]
 		for j, v := range splited {
-			var err error
-			maps[orderList[j]], err = recognizeType(v)
-			if err != nil {
+			if row[j], err = recognizeType(v); err != nil {
 				return nil, err
 			}
 		}
-		maplists = append(maplists, maps)
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// unexpectedEOF reports an array that ended after got of its want elements.
+func unexpectedEOF(scanner *lineScanner, want, got int) error {
+	if err := scanner.Err(); err != nil {
+		return err
 	}
+	return &SyntaxError{msg: fmt.Sprintf("unexpected end of input: array declares %d elements but has %d", want, got), Line: scanner.line}
+}
+
+// unexpectedEnd reports an array that ended after got of its want elements,
+// at line, the first line that is not nested below its header.
+func unexpectedEnd(line, want, got int) error {
+	return &SyntaxError{msg: fmt.Sprintf("unexpected end of array: array declares %d elements but has %d", want, got), Line: line}
+}
 
-	return maplists, nil
+// lengthMismatch reports the inline array at line whose header declares want
+// elements but that has got.
+func lengthMismatch(line, want, got int) error {
+	return &SyntaxError{msg: fmt.Sprintf("array declares %d elements but has %d", want, got), Line: line}
 }
@@ -1,9 +1,15 @@
 package goon_test
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"net/netip"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/roboogg133/goon/goon"
 )
@@ -116,4 +122,717 @@ names: [hello testing here]
 
 	})
 
+	t.Run("scalar classification", func(t *testing.T) {
+		data := []byte(`phone : +1-555-0100
+version : v2
+zip : 05401
+plus : +1
+exp : 1.5e3
+neg : -7
+big : 99999999999999999999
+csv : a,b,c
+quoted : "a \"quote\", and\nnewline"
+tags[3]: a,"b,c",null
+`)
+
+		got := make(map[string]any)
+		if err := goon.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+
+		want := map[string]any{
+			"phone":   "+1-555-0100",
+			"version": "v2",
+			"zip":     "05401",
+			"plus":    "+1",
+			"exp":     1500.0,
+			"neg":     -7,
+			"big":     1e20,
+			"csv":     "a,b,c",
+			"quoted":  "a \"quote\", and\nnewline",
+			"tags":    []any{"a", "b,c", nil},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %#v, want %#v", got, want)
+		}
+	})
+
+	t.Run("non-string map keys", func(t *testing.T) {
+		ints := map[int]string{10: "ten", 2: "two", -1: "minus one"}
+		data, err := goon.Marshal(ints)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var gotInts map[int]string
+		if err := goon.Unmarshal(data, &gotInts); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if !reflect.DeepEqual(gotInts, ints) {
+			t.Errorf("got %v, want %v", gotInts, ints)
+		}
+
+		addrs := map[netip.Addr]int{
+			netip.MustParseAddr("10.0.0.1"): 80,
+			netip.MustParseAddr("10.0.0.2"): 5432,
+		}
+		data, err = goon.Marshal(addrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var gotAddrs map[netip.Addr]int
+		if err := goon.Unmarshal(data, &gotAddrs); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if !reflect.DeepEqual(gotAddrs, addrs) {
+			t.Errorf("got %v, want %v", gotAddrs, addrs)
+		}
+
+		var bad map[uint8]string
+		if err := goon.Unmarshal([]byte(`"300" : overflow`), &bad); err == nil {
+			t.Error("expected an error for an out of range key")
+		}
+	})
+
+	t.Run("quoted keys", func(t *testing.T) {
+		type Contact struct {
+			FirstName string `toon:"first name"`
+			Age       int    `toon:"age"`
+		}
+		type Book struct {
+			Contacts []Contact      `toon:"contacts:all"`
+			Extra    map[string]any `toon:"extra"`
+		}
+
+		keys := map[string]any{
+			"a:b":      1,
+			"-lead":    "x",
+			"":         true,
+			"list[2]":  []any{"a", "b"},
+			`say "hi"`: "hello",
+		}
+		data, err := goon.Marshal(keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `"" : true
+"-lead" : x
+"a:b" : 1
+"list[2]"[2]:
+  - a
+  - b
+"say \"hi\"" : hello`
+		if string(data) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", data, want)
+		}
+
+		got := make(map[string]any)
+		if err := goon.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if !reflect.DeepEqual(got, keys) {
+			t.Errorf("got %#v, want %#v", got, keys)
+		}
+
+		book := Book{Contacts: []Contact{{"Ada", 36}, {"Alan", 41}}}
+		data, err = goon.Marshal(book)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var gotBook Book
+		if err := goon.Unmarshal(data, &gotBook); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if !reflect.DeepEqual(gotBook.Contacts, book.Contacts) {
+			t.Errorf("got %+v, want %+v\n%s", gotBook.Contacts, book.Contacts, data)
+		}
+	})
+
+	t.Run("pointers and null", func(t *testing.T) {
+		type User struct {
+			ID    int     `toon:"id"`
+			Name  *string `toon:"name"`
+			Email *string `toon:"email"`
+			Tags  []any   `toon:"tags"`
+		}
+
+		name := "Ada Lovelace"
+		user := User{ID: 123, Name: &name, Tags: []any{"a", nil}}
+		data, err := goon.Marshal(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		email := "stale@example.com"
+		got := User{Email: &email}
+		if err := goon.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal failed: %v\n%s", err, data)
+		}
+		if got.Name == nil || *got.Name != name {
+			t.Errorf("name: got %v, want %q", got.Name, name)
+		}
+		if got.Email != nil {
+			t.Errorf("email: got %q, want nil", *got.Email)
+		}
+		if !reflect.DeepEqual(got.Tags, user.Tags) {
+			t.Errorf("tags: got %#v, want %#v", got.Tags, user.Tags)
+		}
+	})
+
+	t.Run("pointer targets", func(t *testing.T) {
+		type Targets struct {
+			Name    **string  `toon:"name"`
+			Scores  []*int    `toon:"scores"`
+			Tags    *[]string `toon:"tags"`
+			Level   *int64    `toon:"level"`
+			Cleared *float32  `toon:"cleared"`
+		}
+		data := []byte(`name : Ada
+scores[3]: 1,null,3
+tags[2]: a,b
+level : 7
+cleared : null
+`)
+
+		cleared := float32(1.5)
+		var got *Targets = &Targets{Cleared: &cleared}
+		if err := goon.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if got.Name == nil || *got.Name == nil || **got.Name != "Ada" {
+			t.Errorf("name: got %v", got.Name)
+		}
+		if len(got.Scores) != 3 || *got.Scores[0] != 1 || got.Scores[1] != nil || *got.Scores[2] != 3 {
+			t.Errorf("scores: got %v", got.Scores)
+		}
+		if got.Tags == nil || !reflect.DeepEqual(*got.Tags, []string{"a", "b"}) {
+			t.Errorf("tags: got %v", got.Tags)
+		}
+		if got.Level == nil || *got.Level != 7 {
+			t.Errorf("level: got %v", got.Level)
+		}
+		if got.Cleared != nil {
+			t.Errorf("cleared: got %v, want nil", *got.Cleared)
+		}
+
+		var root **Targets
+		if err := goon.Unmarshal(data, &root); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if root == nil || *root == nil || (*root).Level == nil {
+			t.Errorf("root: got %v", root)
+		}
+
+		values := make(map[string]*int)
+		if err := goon.Unmarshal([]byte("a : 1\nb : null\n"), &values); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if values["a"] == nil || *values["a"] != 1 {
+			t.Errorf("a: got %v", values["a"])
+		}
+		if v, ok := values["b"]; !ok || v != nil {
+			t.Errorf("b: got %v, %t", v, ok)
+		}
+
+		var overflow struct {
+			Small int8 `toon:"small"`
+		}
+		if err := goon.Unmarshal([]byte("small : 300\n"), &overflow); err == nil {
+			t.Error("expected an error for an overflowing int8")
+		}
+	})
+
+	t.Run("nested objects", func(t *testing.T) {
+		var want Test2
+		want.User.ID = 123
+		want.User.Name = "Ada Lovelace"
+		want.User.Content.Email = "ada@example.com"
+		want.User.Content.Phone = "+1-555-0100"
+		want.User.Settings.Theme = "dark"
+		want.User.Settings.Notifications = true
+
+		data, err := goon.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got Test2
+		if err := goon.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if got != want {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+
+		gotMap := make(map[string]any)
+		if err := goon.Unmarshal(data, &gotMap); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		wantMap := map[string]any{
+			"user": map[string]any{
+				"id":   123,
+				"name": "Ada Lovelace",
+				"contact": map[string]any{
+					"email": "ada@example.com",
+					"phone": "+1-555-0100",
+				},
+				"settings": map[string]any{
+					"theme":         "dark",
+					"notifications": true,
+				},
+			},
+		}
+		if !reflect.DeepEqual(gotMap, wantMap) {
+			t.Errorf("got %v, want %v", gotMap, wantMap)
+		}
+	})
+
+	t.Run("unknown and required fields", func(t *testing.T) {
+		type Answer struct {
+			Title  string `toon:"title,required"`
+			Score  int    `toon:"score"`
+			Author struct {
+				Name  string `toon:"name,required"`
+				Email string `toon:"email"`
+			} `toon:"author"`
+			People []Person `toon:"people"`
+		}
+		type Strict struct {
+			Name string `toon:"name,required"`
+			Age  int    `toon:",required"`
+		}
+
+		data := []byte(`score : 9
+confidence : 0.8
+author :
+  email : ada@example.com
+  nickname : ada
+people[1]{name,age,height}:
+  Ada,36,170
+`)
+
+		var answer Answer
+		err := goon.Unmarshal(data, &answer)
+		var fieldErr *goon.FieldError
+		if !errors.As(err, &fieldErr) {
+			t.Fatalf("got %v, want a FieldError", err)
+		}
+		if fieldErr.Unknown != nil {
+			t.Errorf("unknown: got %v, want none without DisallowUnknownFields", fieldErr.Unknown)
+		}
+		if want := []string{"author.name", "title"}; !reflect.DeepEqual(fieldErr.Missing, want) {
+			t.Errorf("missing: got %v, want %v", fieldErr.Missing, want)
+		}
+		if answer.Score != 9 || answer.Author.Email != "ada@example.com" {
+			t.Errorf("got %+v", answer)
+		}
+
+		dec := goon.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&answer)
+		if !errors.As(err, &fieldErr) {
+			t.Fatalf("got %v, want a FieldError", err)
+		}
+		if want := []string{"confidence", "author.nickname", "people[].height"}; !reflect.DeepEqual(fieldErr.Unknown, want) {
+			t.Errorf("unknown: got %v, want %v", fieldErr.Unknown, want)
+		}
+		if want := "goon: unknown keys confidence, author.nickname, people[].height; missing required keys author.name, title"; err.Error() != want {
+			t.Errorf("got %q, want %q", err.Error(), want)
+		}
+
+		var strict struct {
+			People []Strict `toon:"people"`
+		}
+		err = goon.Unmarshal([]byte("people[1]{name}:\n  Ada\n"), &strict)
+		if !errors.As(err, &fieldErr) || !reflect.DeepEqual(fieldErr.Missing, []string{"people[].Age"}) {
+			t.Errorf("got %v, want people[].Age missing", err)
+		}
+
+		var ok Answer
+		if err := goon.Unmarshal([]byte("title : Hi\nauthor :\n  name : Ada\n"), &ok); err != nil {
+			t.Errorf("Unmarshal failed: %v", err)
+		}
+	})
+
+	t.Run("default values", func(t *testing.T) {
+		type Item struct {
+			Name     string `toon:"name"`
+			Quantity int    `toon:"quantity" default:"1"`
+		}
+		type Reply struct {
+			Language string        `toon:"language" default:"en"`
+			Retries  int           `toon:"retries" default:"3"`
+			Strict   *bool         `toon:"strict" default:"true"`
+			Ratio    float32       `toon:"ratio" default:"0.5"`
+			Tags     []string      `toon:"tags" default:"a,b"`
+			Timeout  time.Duration `toon:"timeout" default:"30s"`
+			Explicit bool          `toon:"explicit" default:"true"`
+			Items    []Item        `toon:"items"`
+		}
+
+		data := []byte(`retries : 0
+explicit : false
+items[2]{name}:
+  apple
+  pear
+`)
+		var got Reply
+		if err := goon.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+
+		strict := true
+		want := Reply{
+			Language: "en",
+			Retries:  0,
+			Strict:   &strict,
+			Ratio:    0.5,
+			Tags:     []string{"a", "b"},
+			Timeout:  30 * time.Second,
+			Explicit: false,
+			Items:    []Item{{"apple", 1}, {"pear", 1}},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+
+		var bad struct {
+			Count int `toon:"count" default:"many"`
+		}
+		if err := goon.Unmarshal([]byte("other : 1\n"), &bad); err == nil {
+			t.Error("expected an error for an invalid default")
+		}
+	})
+
+	t.Run("aliases and case-insensitive keys", func(t *testing.T) {
+		type Member struct {
+			FullName string `toon:"name,alias=fullName|full_name"`
+			UserID   int    `toon:"userId,alias=user_id,required"`
+		}
+		type Team struct {
+			Title   string   `toon:"title"`
+			Members []Member `toon:"members"`
+			Lead    Member   `toon:"lead"`
+		}
+
+		data := []byte(`Title : Core
+members[2]{full_name,user_id}:
+  Ada,1
+  Alan,2
+lead :
+  fullName : Grace
+  USERID : 3
+`)
+
+		var got Team
+		dec := goon.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&got)
+		var fieldErr *goon.FieldError
+		if !errors.As(err, &fieldErr) {
+			t.Fatalf("got %v, want a FieldError", err)
+		}
+		if want := []string{"Title", "lead.USERID"}; !reflect.DeepEqual(fieldErr.Unknown, want) {
+			t.Errorf("unknown: got %v, want %v", fieldErr.Unknown, want)
+		}
+
+		got = Team{}
+		var reported []string
+		dec = goon.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		dec.MatchCaseInsensitive()
+		dec.SetAliasReporter(func(path, key string) {
+			reported = append(reported, path+"="+key)
+		})
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+
+		want := Team{
+			Title:   "Core",
+			Members: []Member{{"Ada", 1}, {"Alan", 2}},
+			Lead:    Member{"Grace", 3},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+		wantReported := []string{"title=Title", "members[].name=full_name", "members[].userId=user_id", "lead.name=fullName", "lead.userId=USERID"}
+		if !reflect.DeepEqual(reported, wantReported) {
+			t.Errorf("reported %v, want %v", reported, wantReported)
+		}
+	})
+
+	t.Run("typed tabular arrays", func(t *testing.T) {
+		type Level string
+		type Row struct {
+			Name  Level   `toon:"name"`
+			Age   int64   `toon:"age"`
+			Size  *uint16 `toon:"size"`
+			Score float32 `toon:"score"`
+		}
+		type Tables struct {
+			Values   []Row            `toon:"values"`
+			Pointers []*Row           `toon:"pointers"`
+			Fixed    [2]Row           `toon:"fixed"`
+			Maps     []map[string]int `toon:"maps"`
+			Nested   struct {
+				Rows *[]Row `toon:"rows"`
+			} `toon:"nested"`
+		}
+
+		table := `[2]{name,age,size,score}:
+  Ada,36,170,9.5
+  Alan,41,null,8
+`
+		data := []byte("values" + table + "pointers" + table + "fixed" + table +
+			"maps[2]{a,b}:\n  1,2\n  3,null\n" +
+			"nested :\n  rows" + strings.ReplaceAll(table, "\n  ", "\n    "))
+
+		var got Tables
+		if err := goon.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal failed: %v\n%s", err, data)
+		}
+
+		size := uint16(170)
+		rows := []Row{{"Ada", 36, &size, 9.5}, {"Alan", 41, nil, 8}}
+		if !reflect.DeepEqual(got.Values, rows) {
+			t.Errorf("values: got %+v, want %+v", got.Values, rows)
+		}
+		if len(got.Pointers) != 2 || !reflect.DeepEqual(*got.Pointers[0], rows[0]) || !reflect.DeepEqual(*got.Pointers[1], rows[1]) {
+			t.Errorf("pointers: got %+v", got.Pointers)
+		}
+		if !reflect.DeepEqual(got.Fixed[:], rows) {
+			t.Errorf("fixed: got %+v, want %+v", got.Fixed, rows)
+		}
+		if want := []map[string]int{{"a": 1, "b": 2}, {"a": 3}}; !reflect.DeepEqual(got.Maps, want) {
+			t.Errorf("maps: got %v, want %v", got.Maps, want)
+		}
+		if got.Nested.Rows == nil || !reflect.DeepEqual(*got.Nested.Rows, rows) {
+			t.Errorf("nested: got %+v", got.Nested.Rows)
+		}
+
+		var short struct {
+			Fixed [3]Row `toon:"fixed"`
+		}
+		if err := goon.Unmarshal([]byte("fixed"+table), &short); err == nil {
+			t.Error("expected an error for a fixed array of another length")
+		}
+
+		var syntaxErr *goon.SyntaxError
+		if err := goon.Unmarshal([]byte("values[1]{name,age}:\n  Ada,36,170\n"), &got); !errors.As(err, &syntaxErr) {
+			t.Errorf("got %v, want a SyntaxError for a row with too many values", err)
+		}
+	})
+
+	t.Run("indentation", func(t *testing.T) {
+		type Config struct {
+			Server struct {
+				Host  string   `toon:"host"`
+				Users []Person `toon:"users"`
+				TLS   struct {
+					Cert string `toon:"cert"`
+				} `toon:"tls"`
+			} `toon:"server"`
+			Port int `toon:"port"`
+		}
+		data := "server :\n    host : example.com\n    users[1]{name,age,size}:\n        Ada,36,170\n    tls :\n        cert : a.pem\nport : 8080\n"
+
+		var got Config
+		if err := goon.Unmarshal([]byte(data), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Server.Host != "example.com" || got.Server.TLS.Cert != "a.pem" || got.Port != 8080 ||
+			!reflect.DeepEqual(got.Server.Users, []Person{{"Ada", 36, 170}}) {
+			t.Errorf("got %+v", got)
+		}
+
+		for data, line := range map[string]int{
+			"server :\n    host : example.com\n      port : 1\n":         3,
+			"server :\n\thost : example.com\n":                           2,
+			"server :\n    users[1]{name,age,size}:\n      Ada,36,170\n": 3,
+			"server :\n    host : x\ntags[2]:\n    - a\n      - b\n":     5,
+		} {
+			dec := goon.NewDecoder(strings.NewReader(data))
+			dec.StrictIndentation()
+			var syntaxErr *goon.SyntaxError
+			if err := dec.Decode(&got); !errors.As(err, &syntaxErr) || syntaxErr.Line != line {
+				t.Errorf("%q: got %v, want a SyntaxError at line %d", data, err, line)
+			}
+		}
+	})
+
+	t.Run("array headers", func(t *testing.T) {
+		data := `"a[b]"[2]: 1,2
+pipes[ 2| ]: x,y|z
+tabs[2	]{a	b}:
+  1	2
+  3	4
+empty[0]{a,b}:
+list[1]:
+  - 1
+`
+		var got map[string]any
+		if err := goon.Unmarshal([]byte(data), &got); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]any{
+			"a[b]":  []any{1, 2},
+			"pipes": []any{"x,y", "z"},
+			"tabs":  []map[string]any{{"a": 1, "b": 2}, {"a": 3, "b": 4}},
+			"empty": []map[string]any{},
+			"list":  []any{1},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %#v, want %#v", got, want)
+		}
+	})
+
+	t.Run("list item markers", func(t *testing.T) {
+		var got map[string]any
+		if err := goon.Unmarshal([]byte("a[2]:\n  -\n  - 1\n"), &got); err != nil {
+			t.Fatal(err)
+		}
+		if want := map[string]any{"a": []any{map[string]any{}, 1}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %#v, want %#v", got, want)
+		}
+
+		var syntaxErr *goon.SyntaxError
+		if err := goon.Unmarshal([]byte("a[1]:\n  -5\n"), &got); !errors.As(err, &syntaxErr) || syntaxErr.Line != 2 {
+			t.Errorf("got %v, want a SyntaxError at line 2", err)
+		}
+	})
+
+	t.Run("lists of objects", func(t *testing.T) {
+		type item struct {
+			Name  string         `toon:"name"`
+			Tags  []string       `toon:"tags"`
+			Inner *item          `toon:"inner"`
+			Extra map[string]any `toon:"extra"`
+		}
+		// The items have different keys, so the lists are not tabular and
+		// their objects continue the lines of their markers.
+		items := []item{
+			{Name: "a", Tags: []string{"x", "y"}, Extra: map[string]any{}},
+			{Name: "b", Tags: []string{}, Inner: &item{Name: "c", Tags: []string{}, Extra: map[string]any{}}, Extra: map[string]any{"n": 1}},
+		}
+		mixed := map[string]any{
+			"items": []any{
+				map[string]any{"a": 1},
+				map[string]any{"b": "x", "c": map[string]any{"d": true}},
+				[]any{1, "two"},
+				[]map[string]any{{"a": 1}, {"a": 2}},
+				[]any{map[string]any{"a": 1}, map[string]any{"b": []any{1, map[string]any{"z": 1}}}},
+				"s",
+				nil,
+				map[string]any{},
+				map[string]any{"k": 3, "rows": []map[string]any{{"a": 1}, {"a": 2}}},
+			},
+			"after": 1,
+		}
+
+		data, err := goon.Marshal(mixed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]any
+		if err := goon.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal of\n%s: %v", data, err)
+		}
+		if !reflect.DeepEqual(got, mixed) {
+			t.Errorf("got %#v, want %#v", got, mixed)
+		}
+
+		data, err = goon.Marshal(map[string]any{"items": items, "after": 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v struct {
+			Items    []item   `toon:"items"`
+			Pointers [2]*item `toon:"pointers"`
+			After    int      `toon:"after"`
+		}
+		if err := goon.Unmarshal(data, &v); err != nil {
+			t.Fatalf("Unmarshal of\n%s: %v", data, err)
+		}
+		if !reflect.DeepEqual(v.Items, items) || v.After != 1 {
+			t.Errorf("got %+v, want %+v", v, items)
+		}
+		if err := goon.Unmarshal(bytes.ReplaceAll(data, []byte("items"), []byte("pointers")), &v); err != nil {
+			t.Fatal(err)
+		}
+		if v.Pointers[1] == nil || !reflect.DeepEqual(*v.Pointers[1], items[1]) {
+			t.Errorf("got %+v, want %+v", v.Pointers, items)
+		}
+
+		var syntaxErr *goon.SyntaxError
+		if err := goon.Unmarshal([]byte("a[2]:\n  - x : 1\n  y : 2\n"), &got); !errors.As(err, &syntaxErr) || syntaxErr.Line != 3 {
+			t.Errorf("got %v, want a SyntaxError at line 3", err)
+		}
+	})
+
+	t.Run("short arrays with large headers", func(t *testing.T) {
+		// The length of a header doesn't decide the memory allocated before
+		// the elements are read.
+		for _, data := range []string{
+			"a[300000000]{x,y,z}:\n  1,2,3\n",
+			"a[300000000]:\n  - 1\n",
+		} {
+			var got map[string]any
+			var syntaxErr *goon.SyntaxError
+			if err := goon.Unmarshal([]byte(data), &got); !errors.As(err, &syntaxErr) {
+				t.Errorf("%q: got %v, want a SyntaxError", data, err)
+			}
+		}
+	})
+}
+
+func benchmarkTable(rows int) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "rows[%d]{id,name,score,active}:\n", rows)
+	for i := range rows {
+		fmt.Fprintf(&b, "  %d,user%d,%g,%t\n", i, i, float64(i)/3, i%2 == 0)
+	}
+	return []byte(b.String())
+}
+
+func BenchmarkUnmarshalTable(b *testing.B) {
+	type Row struct {
+		ID     int     `toon:"id"`
+		Name   string  `toon:"name"`
+		Score  float64 `toon:"score"`
+		Active bool    `toon:"active"`
+	}
+	data := benchmarkTable(50000)
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		var v struct {
+			Rows []Row `toon:"rows"`
+		}
+		if err := goon.Unmarshal(data, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalObjects(b *testing.B) {
+	type Item struct {
+		ID   int      `toon:"id"`
+		Name string   `toon:"name"`
+		Tags []string `toon:"tags"`
+	}
+	var buf strings.Builder
+	for i := range 1000 {
+		fmt.Fprintf(&buf, "item%d :\n  id : %d\n  name : item %d\n  tags[2]: a,b\n", i, i, i)
+	}
+	data := []byte(buf.String())
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		v := make(map[string]Item)
+		if err := goon.Unmarshal(data, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
 }
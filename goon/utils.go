@@ -1,67 +1,289 @@
 package goon
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
 )
 
-func recognizeType(s string) (reflect.Value, error) {
+// scalarKind is the type of a TOON scalar token.
+type scalarKind int
+
+const (
+	nullScalar scalarKind = iota
+	stringScalar
+	boolScalar
+	intScalar
+	floatScalar
+)
+
+// A scalar is a classified TOON scalar token.
+type scalar struct {
+	kind scalarKind
+	s    string
+	b    bool
+	i    int
+	f    float64
+}
+
+// parseScalar classifies a single TOON scalar token.
+//
+// Quoted tokens are unescaped and always decode as strings. The literals
+// `true`, `false` and `null` decode as booleans and null. Tokens that follow
+// the numeric grammar (an optional leading `-`, an integer part without
+// leading zeros, an optional fraction and an optional exponent) decode as int,
+// or as float64 when they carry a fraction or exponent or overflow int.
+// Anything else, including `+1`, `05` or `1-555-0100`, is an unquoted string.
+// An empty token decodes as null, meaning "no value".
+func parseScalar(s string) (scalar, error) {
+	s = strings.TrimSpace(s)
+
 	switch {
-	case strings.ContainsAny(s, ",") && !strings.HasPrefix(s, "\""):
-
-		split := strings.Split(s, ",")
-		var elems []reflect.Value
-
-		var typeToSet reflect.Type
-		reflectAny := reflect.TypeOf((*any)(nil)).Elem()
-		for _, v := range split {
-			v = strings.TrimSpace(v)
-			v, _ = strings.CutPrefix(v, "\"")
-			v, _ = strings.CutSuffix(v, "\"")
-			elem, err := recognizeType(v)
-			if err != nil {
-				return reflect.Value{}, err
+	case s == "" || s == "null":
+		return scalar{}, nil
+	case strings.HasPrefix(s, "\""):
+		str, err := unquote(s)
+		if err != nil {
+			return scalar{}, err
+		}
+		return scalar{kind: stringScalar, s: str}, nil
+	case s == "true" || s == "false":
+		return scalar{kind: boolScalar, b: s == "true"}, nil
+	case isNumber(s):
+		if !strings.ContainsAny(s, ".eE") {
+			if i, err := strconv.Atoi(s); err == nil {
+				return scalar{kind: intScalar, i: i}, nil
 			}
-			if typeToSet != reflectAny {
-				if typeToSet != elem.Type() {
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return scalar{}, fmt.Errorf("goon: invalid number %q: %w", s, err)
+		}
+		return scalar{kind: floatScalar, f: f}, nil
+	default:
+		return scalar{kind: stringScalar, s: s}, nil
+	}
+}
 
-					typeToSet = reflectAny
-				} else {
+// typeName returns the name of the Go type a scalar of kind k decodes to, for
+// error messages.
+func (k scalarKind) typeName() string {
+	switch k {
+	case stringScalar:
+		return "string"
+	case boolScalar:
+		return "bool"
+	case intScalar:
+		return "int"
+	case floatScalar:
+		return "float64"
+	}
+	return "null"
+}
 
-					typeToSet = elem.Type()
-				}
-			}
-			elems = append(elems, elem)
+// recognizeType classifies a single TOON scalar token as parseScalar does,
+// returning the decoded value. Null decodes as an invalid value.
+func recognizeType(s string) (reflect.Value, error) {
+	sc, err := parseScalar(s)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	switch sc.kind {
+	case stringScalar:
+		return reflect.ValueOf(sc.s), nil
+	case boolScalar:
+		return reflect.ValueOf(sc.b), nil
+	case intScalar:
+		return reflect.ValueOf(sc.i), nil
+	case floatScalar:
+		return reflect.ValueOf(sc.f), nil
+	}
+	return reflect.Value{}, nil
+}
+
+// recognizeList decodes the values of an inline array such as `a,b,c`,
+// splitting only on sep outside of quoted strings. Every element is classified
+// with recognizeType; empty elements decode as empty strings and `null` as a
+// nil element. The result is always a []any.
+func recognizeList(s string, sep string) (reflect.Value, error) {
+	anyType := reflect.TypeFor[any]()
+
+	if strings.TrimSpace(s) == "" {
+		return reflect.MakeSlice(reflect.SliceOf(anyType), 0, 0), nil
+	}
+
+	split, err := splitDelimited(s, sep)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	sliceValue := reflect.MakeSlice(reflect.SliceOf(anyType), 0, len(split))
+	for _, v := range split {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			sliceValue = reflect.Append(sliceValue, reflect.ValueOf(""))
+			continue
+		}
+
+		elem, err := recognizeType(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if !elem.IsValid() {
+			elem = reflect.Zero(anyType)
 		}
+		sliceValue = reflect.Append(sliceValue, elem)
+	}
 
-		sliceType := reflect.SliceOf(typeToSet)
+	return sliceValue, nil
+}
 
-		sliceValue := reflect.MakeSlice(sliceType, 0, len(elems))
+// isNumber reports whether s matches the TOON numeric grammar:
+// -?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?
+func isNumber(s string) bool {
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
 
-		for _, e := range elems {
-			sliceValue = reflect.Append(sliceValue, e)
+	start := i
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	if i == start {
+		return false
+	}
+	if s[start] == '0' && i-start > 1 {
+		return false
+	}
+
+	if i < len(s) && s[i] == '.' {
+		i++
+		start = i
+		for i < len(s) && isDigit(s[i]) {
+			i++
 		}
+		if i == start {
+			return false
+		}
+	}
 
-		return sliceValue, nil
-	case s == "true":
-		return reflect.ValueOf(true), nil
-	case s == "false":
-		return reflect.ValueOf(false), nil
-	case s == "null":
-		return reflect.ValueOf(nil), nil
-	case strings.ContainsAny(s, "1234567890") && !strings.ContainsAny(s, "\"."):
-		i, err := strconv.Atoi(s)
-		return reflect.ValueOf(i), err
-	case strings.ContainsAny(s, "1234567890") && !strings.Contains(s, "\"") && strings.ContainsAny(s, "."):
-		f, err := strconv.ParseFloat(s, 64)
-		return reflect.ValueOf(f), err
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		start = i
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+		if i == start {
+			return false
+		}
+	}
 
-	case s == "":
-		return reflect.ValueOf(nil), nil
-	default:
-		s, _ = strings.CutPrefix(s, "\"")
-		s, _ = strings.CutSuffix(s, "\"")
-		return reflect.ValueOf(s), nil
+	return i == len(s)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// splitDelimited splits s on sep, ignoring separators that appear inside
+// double-quoted strings. Quotes and escapes are preserved in the returned
+// parts so they can be classified by recognizeType.
+func splitDelimited(s string, sep string) ([]string, error) {
+	var parts []string
+
+	var quoted, escaped bool
+	last := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == sep[0] && strings.HasPrefix(s[i:], sep):
+			parts = append(parts, s[last:i])
+			last = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("goon: unterminated string in %q", s)
+	}
+
+	return append(parts, s[last:]), nil
+}
+
+// unquote decodes a double-quoted TOON string, resolving the escapes
+// \\, \", \n, \r and \t.
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("goon: invalid quoted string %s", s)
+	}
+
+	var builder strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		c := s[i]
+		if c == '"' {
+			return "", fmt.Errorf("goon: invalid quoted string %s", s)
+		}
+		if c != '\\' {
+			builder.WriteByte(c)
+			continue
+		}
+
+		i++
+		if i == len(s)-1 {
+			return "", errors.New("goon: unterminated escape sequence in " + s)
+		}
+		switch s[i] {
+		case '\\':
+			builder.WriteByte('\\')
+		case '"':
+			builder.WriteByte('"')
+		case 'n':
+			builder.WriteByte('\n')
+		case 'r':
+			builder.WriteByte('\r')
+		case 't':
+			builder.WriteByte('\t')
+		default:
+			return "", fmt.Errorf("goon: invalid escape sequence \\%c in %s", s[i], s)
+		}
+	}
+
+	return builder.String(), nil
+}
+
+// quote wraps s in double quotes, escaping backslashes, quotes and control
+// characters so that unquote restores the original string.
+func quote(s string) string {
+	var builder strings.Builder
+	builder.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			builder.WriteString(`\\`)
+		case '"':
+			builder.WriteString(`\"`)
+		case '\n':
+			builder.WriteString(`\n`)
+		case '\r':
+			builder.WriteString(`\r`)
+		case '\t':
+			builder.WriteString(`\t`)
+		default:
+			builder.WriteByte(c)
+		}
 	}
+	builder.WriteByte('"')
+	return builder.String()
 }
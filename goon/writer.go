@@ -0,0 +1,110 @@
+package goon
+
+import "reflect"
+
+// An ObjectWriter carries the state of an encoding through the
+// AppendTOONObject methods of ObjectMarshalers: the options of the encoder,
+// the indentation of the object being written, and the objects and arrays
+// being encoded, for cycle detection. It is the building block of the
+// methods written by goon-gen, which append the keys they encode themselves
+// and hand the others to AppendField.
+type ObjectWriter struct {
+	es *encodeState
+}
+
+// newObjectMarshalerEncoder returns the encoder of the type t, which
+// implements ObjectMarshaler, or whose pointer type does. The latter is only
+// called for addressable values and other values use the encoder of kind.
+func newObjectMarshalerEncoder(t reflect.Type, fallback *typeEncoder) *typeEncoder {
+	addr := !t.Implements(objectMarshalerType)
+	return &typeEncoder{objectShape, func(es *encodeState, v reflect.Value, format string) error {
+		m := v
+		if addr {
+			if !v.CanAddr() {
+				return fallback.encode(es, v, format)
+			}
+			m = v.Addr()
+		}
+		if !m.CanInterface() {
+			return fallback.encode(es, v, format)
+		}
+
+		if err := es.enter(v); err != nil {
+			return err
+		}
+		defer es.leave(v)
+
+		var err error
+		es.buf, err = m.Interface().(ObjectMarshaler).AppendTOONObject(&ObjectWriter{es}, es.buf)
+		return err
+	}}
+}
+
+// Indent appends to dst the indentation of a line of the object, or of a
+// line nested levels below it, such as a row of a tabular array. The first
+// key of an object that is an item of a list continues the line of the
+// `- ` marker and gets no indentation.
+func (w *ObjectWriter) Indent(dst []byte, levels int) []byte {
+	if w.es.inline && levels == 0 {
+		w.es.inline = false
+		return dst
+	}
+	for range w.es.level + levels {
+		dst = append(dst, w.es.unit...)
+	}
+	return dst
+}
+
+// NilSliceAsNull reports whether nil slices are written as `null`, as set by
+// Encoder.SetNilSliceAsNull, instead of as an empty array.
+func (w *ObjectWriter) NilSliceAsNull() bool {
+	return w.es.nilSliceAsNull
+}
+
+// CheckArray fails with an *UnsupportedValueError when the array v, a field
+// of the object, would be nested deeper than the encoder allows.
+func (w *ObjectWriter) CheckArray(v any) error {
+	rv := reflect.ValueOf(v)
+	if err := w.es.enter(rv); err != nil {
+		return err
+	}
+	w.es.leave(rv)
+	return nil
+}
+
+// AppendObject appends the keys of v, an object nested below the current
+// key, one level below the keys of the object. It fails with an
+// *UnsupportedValueError when v is already being written further up, which
+// means the value is cyclic, or when it is nested deeper than the encoder
+// allows.
+func (w *ObjectWriter) AppendObject(dst []byte, v ObjectMarshaler) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	es := w.es
+	if err := es.enter(rv); err != nil {
+		return dst, err
+	}
+	defer es.leave(rv)
+
+	es.level++
+	defer func() { es.level-- }()
+	return v.AppendTOONObject(w, dst)
+}
+
+// AppendField appends the key name of the object and its value v as Marshal
+// writes them for a struct field with the tag tag, with the options of the
+// encoder. It is used by the code generated by goon-gen for the fields it
+// doesn't encode itself.
+func (w *ObjectWriter) AppendField(dst []byte, name string, v any, tag reflect.StructTag) ([]byte, error) {
+	rv := indirectValue(reflect.ValueOf(v))
+	if tag.Get("omitempty") != "" && isNil(rv) {
+		return dst, nil
+	}
+
+	es := w.es
+	es.buf = dst
+	err := es.encodeKey(formatKey(name), rv, tag.Get("format"))
+	return es.buf, err
+}